@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/newtonproject/newchain-api-express/params"
+)
+
+// SendTxResult is the result of one transaction in a batch submission
+type SendTxResult struct {
+	Hash  *common.Hash `json:"hash,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+var errPreviousNonceFailed = errors.New("transaction with previous nonce of the sender failed")
+
+// maxBatchTransactions is the max number of transactions in one batch submission
+const maxBatchTransactions = 1000
+
+// batchTx is a valid transaction of a batch submission
+type batchTx struct {
+	index  int
+	tx     *types.Transaction
+	from   common.Address
+	wait   uint64
+	record *TxRecord // the record of the tx submitted before, nil if new
+}
+
+// SendRawTransactions submits the signed raw transactions in batch.
+// The transactions are validated all, then broadcast in nonce order per sender.
+func (s *Server) SendRawTransactions(ctx context.Context, args []SendRawTxArgs) ([]*SendTxResult, error) {
+	if len(args) > maxBatchTransactions {
+		return nil, fmt.Errorf("too many transactions, want at most %d", maxBatchTransactions)
+	}
+
+	results := make([]*SendTxResult, len(args))
+	txs := make([]*batchTx, 0, len(args))
+	for i, arg := range args {
+		tx, from, err := s.decodeRawTx(arg)
+		if err != nil {
+			results[i] = &SendTxResult{Error: err.Error()}
+			continue
+		}
+		if btx := s.prepareBatchTx(i, tx, from, arg.Wait, arg.IdempotencyKey, results); btx != nil {
+			txs = append(txs, btx)
+		}
+	}
+
+	s.submitBatch(ctx, txs, results)
+
+	return results, nil
+}
+
+// SendTransactions submits the unsigned raw transactions with signature in batch.
+// The transactions are validated all, then broadcast in nonce order per sender.
+func (s *Server) SendTransactions(ctx context.Context, args []SendTxArgs) ([]*SendTxResult, error) {
+	if len(args) > maxBatchTransactions {
+		return nil, fmt.Errorf("too many transactions, want at most %d", maxBatchTransactions)
+	}
+
+	results := make([]*SendTxResult, len(args))
+	txs := make([]*batchTx, 0, len(args))
+	for i, arg := range args {
+		tx, err := s.assembleTx(arg)
+		if err != nil {
+			results[i] = &SendTxResult{Error: err.Error()}
+			continue
+		}
+		if btx := s.prepareBatchTx(i, tx, arg.From.Address, arg.Wait, arg.IdempotencyKey, results); btx != nil {
+			txs = append(txs, btx)
+		}
+	}
+
+	s.submitBatch(ctx, txs, results)
+
+	return results, nil
+}
+
+// prepareBatchTx checks the idempotency key and the tracked state of the tx.
+// It returns nil and sets the result if the tx has been submitted and reached the wait level, or the check failed.
+// The tx submitted but not broadcast or not confirmed as waited is submitted again with its record.
+func (s *Server) prepareBatchTx(index int, tx *types.Transaction, from common.Address, wait uint64, key string, results []*SendTxResult) *batchTx {
+	wait = checkWait(wait)
+	record, err := s.prepareSubmission(tx, from, key)
+	if err != nil {
+		results[index] = newSendTxResult(common.Hash{}, err)
+		return nil
+	}
+	if record != nil && submittedDone(record, wait) {
+		results[index] = newSendTxResult(record.Hash, nil)
+		return nil
+	}
+
+	return &batchTx{index: index, tx: tx, from: from, wait: wait, record: record}
+}
+
+// submitBatch groups the txs by sender and submits the txs of each sender in nonce order.
+// The results are set by the index of the tx.
+func (s *Server) submitBatch(ctx context.Context, txs []*batchTx, results []*SendTxResult) {
+	senders := make(map[common.Address][]*batchTx)
+	for _, tx := range txs {
+		senders[tx.from] = append(senders[tx.from], tx)
+	}
+
+	var wg sync.WaitGroup
+	for _, list := range senders {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].tx.Nonce() < list[j].tx.Nonce()
+		})
+
+		wg.Add(1)
+		go func(list []*batchTx) {
+			defer wg.Done()
+			s.submitSenderTxs(ctx, list, results)
+		}(list)
+	}
+	wg.Wait()
+
+	for _, tx := range txs {
+		var err error
+		if msg := results[tx.index].Error; msg != "" {
+			err = errors.New(msg)
+		}
+		s.metrics.observeSubmission(tx.wait, err)
+	}
+}
+
+// submitSenderTxs submits the sorted txs of one sender.
+// If all txs are new and need no wait, they are queued to be broadcast in order,
+// otherwise they are broadcast in order and then waited to be confirmed if required.
+// The txs submitted before are broadcast again and waited as submitTrackedTx.
+func (s *Server) submitSenderTxs(ctx context.Context, list []*batchTx, results []*SendTxResult) {
+	maxWait := uint64(params.LevelNoWait)
+	resumed := false
+	for _, btx := range list {
+		if btx.wait > maxWait {
+			maxWait = btx.wait
+		}
+		if btx.record != nil {
+			resumed = true
+		}
+	}
+
+	if maxWait == params.LevelNoWait && !resumed {
+		for _, btx := range list {
+			hash, err := s.submitTx(ctx, btx.tx, btx.from, params.LevelNoWait)
+			results[btx.index] = newSendTxResult(hash, err)
+		}
+		return
+	}
+
+	client, err := ethclient.Dial(s.rpcURL)
+	if err != nil {
+		for _, btx := range list {
+			s.trackFailed(btx.tx, err)
+			results[btx.index] = newSendTxResult(common.Hash{}, err)
+		}
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed bool
+	)
+	for _, btx := range list {
+		if failed {
+			s.trackFailed(btx.tx, errPreviousNonceFailed)
+			results[btx.index] = newSendTxResult(common.Hash{}, errPreviousNonceFailed)
+			continue
+		}
+
+		if btx.record != nil {
+			if err := s.rebroadcastSubmitted(ctx, client, btx.tx, btx.from, btx.record); err != nil {
+				results[btx.index] = newSendTxResult(common.Hash{}, err)
+				failed = true
+				continue
+			}
+
+			wg.Add(1)
+			go func(btx *batchTx) {
+				defer wg.Done()
+				err := s.waitSubmitted(ctx, client, btx.tx, btx.from, btx.record, btx.wait)
+				results[btx.index] = newSendTxResult(btx.record.Hash, err)
+			}(btx)
+			continue
+		}
+
+		// notify received
+		s.txChan <- txNotifyReceived{tx: newTransferTx(btx.tx, btx.from)}
+
+		if err := s.broadcastTx(ctx, client, btx.tx, btx.from); err != nil {
+			s.trackFailed(btx.tx, err)
+			results[btx.index] = newSendTxResult(common.Hash{}, err)
+			failed = true
+			continue
+		}
+
+		if btx.wait != params.LevelWaitConfirmed {
+			s.txChan <- tx2Confirm{tx: btx.tx, from: btx.from}
+			results[btx.index] = newSendTxResult(btx.tx.Hash(), nil)
+			continue
+		}
+
+		wg.Add(1)
+		go func(btx *batchTx) {
+			defer wg.Done()
+			err := s.waitConfirmed(ctx, client, btx.tx, btx.from)
+			results[btx.index] = newSendTxResult(btx.tx.Hash(), err)
+		}(btx)
+	}
+	wg.Wait()
+}
+
+func newSendTxResult(hash common.Hash, err error) *SendTxResult {
+	if err != nil {
+		return &SendTxResult{Error: err.Error()}
+	}
+	return &SendTxResult{Hash: &hash}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	defaultMaxHeadAge    = time.Minute
+	defaultMaxQueueUsage = 0.9
+
+	statusTimeout       = 5 * time.Second
+	gasPriceInterval    = time.Hour
+	gasPriceRetryPeriod = time.Minute
+)
+
+// HealthConfig is the thresholds of the readiness
+type HealthConfig struct {
+	MaxHeadAge    time.Duration // not ready if the latest block of the node is older
+	MaxQueueUsage float64       // not ready if the tx queue is fuller, e.g. 0.9 for 90%
+}
+
+// NodeStatus is the state of the upstream NewChain node
+type NodeStatus struct {
+	Reachable    bool            `json:"reachable"`
+	Error        string          `json:"error,omitempty"`
+	Syncing      bool            `json:"syncing"`
+	CurrentBlock *hexutil.Uint64 `json:"currentBlock,omitempty"`
+	HighestBlock *hexutil.Uint64 `json:"highestBlock,omitempty"`
+	BlockNumber  *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	HeadAge      uint64          `json:"headAge"` // seconds since the latest block
+}
+
+// NotifierStatus is the state of the MQTT connection
+type NotifierStatus struct {
+	Connected bool `json:"connected"`
+}
+
+// QueueStatus is the saturation of the tx queue
+type QueueStatus struct {
+	Length               int `json:"length"`
+	Capacity             int `json:"capacity"`
+	PendingConfirmations int `json:"pendingConfirmations"`
+}
+
+// GasPriceStatus is the gas price of the server and its age
+type GasPriceStatus struct {
+	GasPrice *hexutil.Big `json:"gasPrice"`
+	Age      uint64       `json:"age"` // seconds since the last update
+}
+
+// Status is the health of the server, it is ready to serve if Issues is empty
+type Status struct {
+	Ready    bool           `json:"ready"`
+	Issues   []string       `json:"issues,omitempty"`
+	Node     NodeStatus     `json:"node"`
+	Notifier NotifierStatus `json:"notifier"`
+	Queue    QueueStatus    `json:"queue"`
+	GasPrice GasPriceStatus `json:"gasPrice"`
+}
+
+// Status returns the state of the upstream node, the notifier, the tx queue and the gas price
+func (s *Server) Status(ctx context.Context) (*Status, error) {
+	return s.status(ctx), nil
+}
+
+func (s *Server) status(ctx context.Context) *Status {
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	status := &Status{
+		Node:     s.nodeStatus(ctx),
+		Notifier: NotifierStatus{Connected: s.nc != nil && s.nc.IsConnectionOpen()},
+		Queue: QueueStatus{
+			Length:   len(s.txChan),
+			Capacity: cap(s.txChan),
+		},
+	}
+
+	s.txs2ConfirmLock.Lock()
+	status.Queue.PendingConfirmations = len(s.txs2Confirm)
+	s.txs2ConfirmLock.Unlock()
+
+	gasPrice, updatedAt := s.getGasPrice()
+	status.GasPrice = GasPriceStatus{
+		GasPrice: (*hexutil.Big)(gasPrice),
+		Age:      uint64(time.Since(updatedAt).Seconds()),
+	}
+
+	config := s.health
+	if config == nil {
+		config = &HealthConfig{}
+	}
+	maxHeadAge := config.MaxHeadAge
+	if maxHeadAge <= 0 {
+		maxHeadAge = defaultMaxHeadAge
+	}
+	maxQueueUsage := config.MaxQueueUsage
+	if maxQueueUsage <= 0 {
+		maxQueueUsage = defaultMaxQueueUsage
+	}
+
+	switch {
+	case !status.Node.Reachable:
+		status.Issues = append(status.Issues, "node unreachable")
+	case status.Node.Syncing:
+		status.Issues = append(status.Issues, "node syncing")
+	case time.Duration(status.Node.HeadAge)*time.Second > maxHeadAge:
+		status.Issues = append(status.Issues, fmt.Sprintf("node head is %ds old", status.Node.HeadAge))
+	}
+	if !status.Notifier.Connected {
+		status.Issues = append(status.Issues, "notifier disconnected")
+	}
+	if status.Queue.Capacity > 0 && float64(status.Queue.Length) >= maxQueueUsage*float64(status.Queue.Capacity) {
+		status.Issues = append(status.Issues, "tx queue saturated")
+	}
+	status.Ready = len(status.Issues) == 0
+
+	return status
+}
+
+func (s *Server) nodeStatus(ctx context.Context) NodeStatus {
+	var status NodeStatus
+
+	client, err := ethclient.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer client.Close()
+
+	start := time.Now()
+	header, err := client.HeaderByNumber(ctx, nil)
+	s.metrics.observeUpstream("status", start)
+	if err != nil {
+		s.metrics.observeUpstreamError("status")
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	number := hexutil.Uint64(header.Number.Uint64())
+	status.BlockNumber = &number
+	if now := uint64(time.Now().Unix()); now > header.Time {
+		status.HeadAge = now - header.Time
+	}
+
+	progress, err := client.SyncProgress(ctx)
+	if err != nil {
+		s.metrics.observeUpstreamError("status")
+		status.Error = err.Error()
+		return status
+	}
+	if progress != nil {
+		status.Syncing = true
+		current, highest := hexutil.Uint64(progress.CurrentBlock), hexutil.Uint64(progress.HighestBlock)
+		status.CurrentBlock, status.HighestBlock = &current, &highest
+	}
+
+	return status
+}
+
+// getGasPrice returns the gas price and the time it is updated
+func (s *Server) getGasPrice() (*big.Int, time.Time) {
+	s.gasPriceLock.RLock()
+	defer s.gasPriceLock.RUnlock()
+
+	return s.gasPrice, s.gasPriceUpdatedAt
+}
+
+// updateGasPrice refreshes the suggested gas price hourly, and retries in a minute if failed
+func (s *Server) updateGasPrice() {
+	interval := gasPriceInterval
+	for {
+		time.Sleep(interval)
+
+		interval = gasPriceInterval
+		if err := s.refreshGasPrice(); err != nil {
+			s.metrics.observeUpstreamError("suggestGasPrice")
+			log.Errorf("Update gas price error: %v\n", err)
+			interval = gasPriceRetryPeriod
+		}
+	}
+}
+
+func (s *Server) refreshGasPrice() error {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	start := time.Now()
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	s.metrics.observeUpstream("suggestGasPrice", start)
+	if err != nil {
+		return err
+	}
+
+	s.gasPriceLock.Lock()
+	defer s.gasPriceLock.Unlock()
+	s.gasPrice, s.gasPriceUpdatedAt = gasPrice, time.Now()
+
+	return nil
+}
+
+// HealthHandler serves the liveness probe, it always responds 200 with the status while the server is running
+func HealthHandler(s *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, s.status(r.Context()), http.StatusOK)
+	})
+}
+
+// ReadyHandler serves the readiness probe, it responds 503 if the node is behind, the notifier is down or the queue is saturated
+func ReadyHandler(s *Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := s.status(r.Context())
+		code := http.StatusOK
+		if !status.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeStatus(w, status, code)
+	})
+}
+
+func writeStatus(w http.ResponseWriter, status *Status, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	s := &Server{rpcURL: "http://127.0.0.1:1", txChan: make(chan interface{}, 10)}
+	for i := 0; i < 9; i++ {
+		s.txChan <- struct{}{}
+	}
+
+	for _, c := range []struct {
+		handler http.Handler
+		code    int
+	}{
+		{HealthHandler(s), http.StatusOK},
+		{ReadyHandler(s), http.StatusServiceUnavailable},
+	} {
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != c.code {
+			t.Errorf("code = %d, want %d", w.Code, c.code)
+		}
+
+		var status Status
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Ready || status.Node.Reachable || status.Notifier.Connected {
+			t.Errorf("status = %+v, want not ready", status)
+		}
+		want := []string{"node unreachable", "notifier disconnected", "tx queue saturated"}
+		if len(status.Issues) != len(want) {
+			t.Fatalf("issues = %v, want %v", status.Issues, want)
+		}
+		for i := range want {
+			if status.Issues[i] != want[i] {
+				t.Errorf("issues = %v, want %v", status.Issues, want)
+			}
+		}
+	}
+}
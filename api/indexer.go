@@ -0,0 +1,404 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/newtonproject/newchain-api-express/utils"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// IndexerConfig is the config of the address transaction history indexer
+type IndexerConfig struct {
+	Enabled       bool
+	StartHeight   uint64 // the first block to index
+	Confirmations uint64 // only index the blocks with enough confirmations
+}
+
+// direction of the tx to the address
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+var directionCode = map[string]byte{
+	DirectionIn:   1,
+	DirectionOut:  2,
+	DirectionSelf: 3,
+}
+
+// transferEventSig is the topic of event Transfer(address indexed from, address indexed to, uint256 value)
+var transferEventSig = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// HistoryTx is a native transfer or a token Transfer log of the address
+type HistoryTx struct {
+	Hash        common.Hash     `json:"hash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Timestamp   hexutil.Uint64  `json:"timestamp"`
+	TxIndex     hexutil.Uint    `json:"transactionIndex"`
+	From        common.Address  `json:"from"`
+	To          *common.Address `json:"to"`
+	FromNEW     string          `json:"fromNEW,omitempty"`
+	ToNEW       string          `json:"toNEW,omitempty"`
+	Value       *hexutil.Big    `json:"value"`
+	Token       *common.Address `json:"token,omitempty"`
+	LogIndex    *hexutil.Uint   `json:"logIndex,omitempty"`
+	Direction   string          `json:"direction"`
+}
+
+// historyKey is his-<address><blockNumber><txIndex><seq><direction>,
+// seq is 0 for the native transfer and logIndex+1 for the token Transfer log
+func historyKey(address common.Address, number uint64, txIndex uint32, seq uint32, direction string) []byte {
+	key := storeKey(prefixHistory, address.Bytes())
+	suffix := make([]byte, 8+4+4+1)
+	binary.BigEndian.PutUint64(suffix[:8], number)
+	binary.BigEndian.PutUint32(suffix[8:12], txIndex)
+	binary.BigEndian.PutUint32(suffix[12:16], seq)
+	suffix[16] = directionCode[direction]
+
+	return append(key, suffix...)
+}
+
+// indexer follows the blocks and stores the transactions per address
+type indexer struct {
+	rpcURL    string
+	networkID uint64
+	config    *IndexerConfig
+	store     *store
+	metrics   *Metrics
+}
+
+func newIndexer(rpcURL string, networkID uint64, config *IndexerConfig, st *store, metrics *Metrics) *indexer {
+	return &indexer{
+		rpcURL:    rpcURL,
+		networkID: networkID,
+		config:    config,
+		store:     st,
+		metrics:   metrics,
+	}
+}
+
+// next returns the next block number to index
+func (idx *indexer) next() (uint64, error) {
+	var next uint64
+	found, err := idx.store.get(keyIndexerNext, &next)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return idx.config.StartHeight, nil
+	}
+
+	return next, nil
+}
+
+// the wait between the syncs of the indexer, doubled after each failed sync up to indexerMaxBackoff
+const (
+	indexerInterval   = 3 * time.Second
+	indexerMaxBackoff = 5 * time.Minute
+)
+
+// indexBlockError is the error of the block which cannot be indexed, e.g. the sender cannot be recovered.
+// The other errors of the sync are the errors of the node or the store.
+type indexBlockError struct {
+	number uint64
+	err    error
+}
+
+func (e *indexBlockError) Error() string {
+	return fmt.Sprintf("index block %d: %v", e.number, e.err)
+}
+
+func (idx *indexer) run() {
+	if next, err := idx.next(); err != nil {
+		log.Errorf("Indexer get next block error: %v\n", err)
+	} else {
+		log.Infof("Indexer start from block %d\n", next)
+	}
+
+	backoff := indexerInterval
+	for {
+		time.Sleep(idx.step(&backoff))
+	}
+}
+
+// step syncs once and returns the wait before the next sync,
+// the backoff is reset after a successful sync and doubled after a failed one
+func (idx *indexer) step(backoff *time.Duration) time.Duration {
+	err := idx.sync()
+	if err == nil {
+		*backoff = indexerInterval
+		return indexerInterval
+	}
+
+	if _, ok := err.(*indexBlockError); ok {
+		idx.metrics.observeIndexerBlockError()
+	} else {
+		idx.metrics.observeUpstreamError("indexer")
+	}
+	wait := *backoff
+	log.Errorf("Indexer sync error: %v, retry in %v\n", err, wait)
+	*backoff = indexerBackoff(wait)
+
+	return wait
+}
+
+// indexerBackoff returns the wait after another failed sync
+func indexerBackoff(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > indexerMaxBackoff {
+		interval = indexerMaxBackoff
+	}
+	return interval
+}
+
+// sync indexes the blocks up to the latest block with enough confirmations
+func (idx *indexer) sync() error {
+	client, err := ethclient.Dial(idx.rpcURL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	latest, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if latest.Number.Uint64() < idx.config.Confirmations {
+		return nil
+	}
+	head := latest.Number.Uint64() - idx.config.Confirmations
+
+	next, err := idx.next()
+	if err != nil {
+		return err
+	}
+
+	for ; next <= head; next++ {
+		if err := idx.indexBlock(ctx, client, next); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexBlock stores the native transfers and token Transfer logs of the block
+// and moves the next block number forward atomically
+func (idx *indexer) indexBlock(ctx context.Context, client *ethclient.Client, number uint64) error {
+	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return err
+	}
+
+	signer := types.NewEIP155Signer(new(big.Int).SetUint64(idx.networkID))
+	batch := new(leveldb.Batch)
+	txIndexes := make(map[common.Hash]uint32)
+	for i, tx := range block.Transactions() {
+		txIndexes[tx.Hash()] = uint32(i)
+
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return &indexBlockError{number: number, err: err}
+		}
+		htx := &HistoryTx{
+			Hash:        tx.Hash(),
+			BlockNumber: hexutil.Uint64(number),
+			Timestamp:   hexutil.Uint64(block.Time()),
+			TxIndex:     hexutil.Uint(i),
+			From:        from,
+			To:          tx.To(),
+			Value:       (*hexutil.Big)(tx.Value()),
+		}
+		if err := putHistory(batch, htx, uint32(i), 0); err != nil {
+			return err
+		}
+	}
+
+	blockHash := block.Hash()
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &blockHash,
+		Topics:    [][]common.Hash{{transferEventSig}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, l := range logs {
+		htx := transferLogToHistory(l, block.Time())
+		if htx == nil {
+			continue
+		}
+		if err := putHistory(batch, htx, txIndexes[l.TxHash], uint32(l.Index)+1); err != nil {
+			return err
+		}
+	}
+
+	if err := batchPut(batch, keyIndexerNext, number+1); err != nil {
+		return err
+	}
+
+	return idx.store.write(batch)
+}
+
+// transferLogToHistory decodes the token Transfer log, returns nil if not ERC20 Transfer
+func transferLogToHistory(l types.Log, timestamp uint64) *HistoryTx {
+	if len(l.Topics) != 3 || l.Topics[0] != transferEventSig || len(l.Data) != 32 {
+		return nil
+	}
+
+	token := l.Address
+	to := common.BytesToAddress(l.Topics[2].Bytes())
+	logIndex := hexutil.Uint(l.Index)
+	return &HistoryTx{
+		Hash:        l.TxHash,
+		BlockNumber: hexutil.Uint64(l.BlockNumber),
+		Timestamp:   hexutil.Uint64(timestamp),
+		TxIndex:     hexutil.Uint(l.TxIndex),
+		From:        common.BytesToAddress(l.Topics[1].Bytes()),
+		To:          &to,
+		Value:       (*hexutil.Big)(new(big.Int).SetBytes(l.Data)),
+		Token:       &token,
+		LogIndex:    &logIndex,
+	}
+}
+
+// putHistory puts the tx into the batch for both from and to address
+func putHistory(batch *leveldb.Batch, htx *HistoryTx, txIndex, seq uint32) error {
+	number := uint64(htx.BlockNumber)
+	if htx.To != nil && *htx.To == htx.From {
+		htx.Direction = DirectionSelf
+		return batchPut(batch, historyKey(htx.From, number, txIndex, seq, DirectionSelf), htx)
+	}
+
+	out := *htx
+	out.Direction = DirectionOut
+	if err := batchPut(batch, historyKey(htx.From, number, txIndex, seq, DirectionOut), &out); err != nil {
+		return err
+	}
+
+	if htx.To == nil {
+		return nil
+	}
+	in := *htx
+	in.Direction = DirectionIn
+	return batchPut(batch, historyKey(*htx.To, number, txIndex, seq, DirectionIn), &in)
+}
+
+// max number of transactions in one page
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// GetTransactionsArgs address, cursor, limit and direction of the history.
+// Direction is in, out or empty for all, Order is desc (newest first, default) or asc.
+type GetTransactionsArgs struct {
+	Address   utils.Address `json:"address"`
+	Cursor    hexutil.Bytes `json:"cursor"`
+	Limit     uint64        `json:"limit"`
+	Direction string        `json:"direction"`
+	Order     string        `json:"order"`
+}
+
+// TransactionsPage is a page of the transaction history of the address
+type TransactionsPage struct {
+	Transactions  []*HistoryTx   `json:"transactions"`
+	NextCursor    hexutil.Bytes  `json:"nextCursor,omitempty"`
+	IndexedHeight hexutil.Uint64 `json:"indexedHeight"`
+}
+
+// GetTransactions returns the transaction history of the address from the indexer
+func (s *Server) GetTransactions(ctx context.Context, args GetTransactionsArgs) (*TransactionsPage, error) {
+	if s.indexer == nil {
+		return nil, errors.New("indexer is not enabled")
+	}
+	if err := s.checkAddresses(&args.Address); err != nil {
+		return nil, err
+	}
+
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	} else if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	var reverse bool
+	switch args.Order {
+	case "", "desc":
+		reverse = true
+	case "asc":
+	default:
+		return nil, errors.New("order only desc or asc")
+	}
+
+	switch args.Direction {
+	case "", DirectionIn, DirectionOut:
+	default:
+		return nil, errors.New("direction only in or out")
+	}
+
+	prefix := storeKey(prefixHistory, args.Address.Bytes())
+	start, end := prefix, storeKey(prefix, []byte{0xff})
+	if len(args.Cursor) > 0 {
+		cursor := storeKey(prefix, args.Cursor)
+		if reverse {
+			end = cursor
+		} else {
+			start = storeKey(cursor, []byte{0})
+		}
+	}
+
+	page := &TransactionsPage{Transactions: make([]*HistoryTx, 0)}
+	var (
+		lastKey []byte
+		decErr  error
+	)
+	err := s.store.iterateRange(start, end, reverse, func(key, value []byte) bool {
+		if uint64(len(page.Transactions)) >= limit {
+			page.NextCursor = bytes.TrimPrefix(lastKey, prefix)
+			return false
+		}
+		lastKey = append(lastKey[:0], key...)
+
+		htx := new(HistoryTx)
+		if decErr = json.Unmarshal(value, htx); decErr != nil {
+			return false
+		}
+		if args.Direction != "" && htx.Direction != args.Direction && htx.Direction != DirectionSelf {
+			return true
+		}
+		htx.FromNEW, htx.ToNEW = s.addressNEW(&htx.From), s.addressNEW(htx.To)
+		page.Transactions = append(page.Transactions, htx)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decErr != nil {
+		return nil, decErr
+	}
+
+	next, err := s.indexer.next()
+	if err != nil {
+		return nil, err
+	}
+	if next > 0 {
+		page.IndexedHeight = hexutil.Uint64(next - 1)
+	}
+
+	return page, nil
+}
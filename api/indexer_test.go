@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/rpc"
+	"github.com/newtonproject/newchain-api-express/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestGetTransactionsPaging(t *testing.T) {
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+
+	s.indexer = newIndexer("", 1007, &IndexerConfig{}, s.store, nil)
+
+	addr := common.HexToAddress("0xd639a62be604374ff04af4112a555890bd822a03")
+	other := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	batch := new(leveldb.Batch)
+	for i := uint64(1); i <= 5; i++ {
+		from, to := addr, other
+		if i%2 == 0 {
+			from, to = other, addr
+		}
+		htx := &HistoryTx{
+			Hash:        common.BigToHash(new(big.Int).SetUint64(i)),
+			BlockNumber: hexutil.Uint64(i),
+			From:        from,
+			To:          &to,
+			Value:       (*hexutil.Big)(big.NewInt(1)),
+		}
+		if err := putHistory(batch, htx, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batchPut(batch, keyIndexerNext, uint64(6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []uint64
+	var cursor hexutil.Bytes
+	for {
+		page, err := s.GetTransactions(context.Background(), GetTransactionsArgs{Address: utils.Address{Address: addr}, Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.IndexedHeight != 5 {
+			t.Fatalf("indexed height: want 5, got %d", page.IndexedHeight)
+		}
+		for _, tx := range page.Transactions {
+			numbers = append(numbers, uint64(tx.BlockNumber))
+		}
+		if len(page.NextCursor) == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if want := []uint64{5, 4, 3, 2, 1}; !equalUint64s(numbers, want) {
+		t.Fatalf("desc: want %v, got %v", want, numbers)
+	}
+
+	page, err := s.GetTransactions(context.Background(), GetTransactionsArgs{Address: utils.Address{Address: addr}, Direction: DirectionIn, Order: "asc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numbers = numbers[:0]
+	for _, tx := range page.Transactions {
+		if tx.Direction != DirectionIn {
+			t.Fatalf("direction: want in, got %s", tx.Direction)
+		}
+		numbers = append(numbers, uint64(tx.BlockNumber))
+	}
+	if want := []uint64{2, 4}; !equalUint64s(numbers, want) {
+		t.Fatalf("in asc: want %v, got %v", want, numbers)
+	}
+}
+
+func equalUint64s(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexerBackoff(t *testing.T) {
+	backoff := indexerInterval
+	for i := 0; i < 10; i++ {
+		next := indexerBackoff(backoff)
+		if next < backoff || next > indexerMaxBackoff {
+			t.Fatalf("backoff %v after %v", next, backoff)
+		}
+		backoff = next
+	}
+	if backoff != indexerMaxBackoff {
+		t.Errorf("backoff: want %v, got %v", indexerMaxBackoff, backoff)
+	}
+}
+
+func TestIndexerRetry(t *testing.T) {
+	upstream := &ChainService{head: 5, down: true}
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	metrics := NewMetrics()
+	idx := newIndexer(upstreamHTTP.URL, 1007, &IndexerConfig{StartHeight: 1}, s.store, metrics)
+
+	upstreamErrors := metrics.upstreamErrors.WithLabelValues("indexer")
+	next := func() uint64 {
+		n, err := idx.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// the errors of the node are counted as upstream errors and retried with backoff
+	backoff := indexerInterval
+	for i := 0; i < 3; i++ {
+		if wait := idx.step(&backoff); wait != indexerInterval<<uint(i) {
+			t.Fatalf("retry %d: want wait %v, got %v", i, indexerInterval<<uint(i), wait)
+		}
+	}
+	if n := testutil.ToFloat64(upstreamErrors); n != 3 {
+		t.Errorf("upstream errors: want 3, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.indexerErrors); n != 0 {
+		t.Errorf("block errors: want 0, got %v", n)
+	}
+
+	// the backoff is reset once the node is back
+	upstream.setDown(false)
+	if wait := idx.step(&backoff); wait != indexerInterval || backoff != indexerInterval {
+		t.Fatalf("recovered: want wait %v, got %v and backoff %v", indexerInterval, wait, backoff)
+	}
+	if n := next(); n != 6 {
+		t.Fatalf("next: want 6, got %d", n)
+	}
+
+	// the block with the sender cannot be recovered is counted as a block error and stops the indexer
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream.lock.Lock()
+	upstream.head, upstream.txs = 7, map[uint64]types.Transactions{7: {tx}}
+	upstream.lock.Unlock()
+	for i := 0; i < 2; i++ {
+		idx.step(&backoff)
+	}
+	if n := testutil.ToFloat64(metrics.indexerErrors); n != 2 {
+		t.Errorf("block errors: want 2, got %v", n)
+	}
+	if n := testutil.ToFloat64(upstreamErrors); n != 3 {
+		t.Errorf("upstream errors: want 3, got %v", n)
+	}
+	if n := next(); n != 7 {
+		t.Fatalf("next: want stopped at 7, got %d", n)
+	}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "newchain_api_express"
+
+// result label of the metrics
+const (
+	resultOK      = "ok"
+	resultError   = "error"
+	resultTimeout = "timeout"
+	resultDropped = "dropped"
+)
+
+// stageTimerSize is the max number of the txs timed by the stage timer,
+// the oldest are evicted if the txs are never mined, e.g. dropped by the node
+const stageTimerSize = 10000
+
+// Metrics is the prometheus metrics of a server, registered in its own registry,
+// so the servers in the same process never conflict
+type Metrics struct {
+	registry *prometheus.Registry
+
+	rpcRequests      *prometheus.CounterVec
+	rpcDuration      *prometheus.HistogramVec
+	submissions      *prometheus.CounterVec
+	stageLatency     *prometheus.HistogramVec
+	upstreamErrors   *prometheus.CounterVec
+	upstreamDuration *prometheus.HistogramVec
+	notifications    *prometheus.CounterVec
+	indexerErrors    prometheus.Counter
+}
+
+// NewMetrics creates the metrics with a new registry, including the Go and process metrics
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		rpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_requests_total",
+			Help:      "The number of the RPC requests by method and result.",
+		}, []string{"method", "result"}),
+
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_request_duration_seconds",
+			Help:      "The duration of the RPC requests by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"method"}),
+
+		submissions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "submissions_total",
+			Help:      "The number of the submitted transactions by wait level and result.",
+		}, []string{"wait", "result"}),
+
+		stageLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_stage_latency_seconds",
+			Help:      "The latency from received to broadcast and to mined of the submitted transactions.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"stage"}),
+
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_errors_total",
+			Help:      "The number of the errors of the calls to the NewChain node by operation.",
+		}, []string{"op"}),
+
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "The duration of the calls to the NewChain node by operation.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"op"}),
+
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "notifications_total",
+			Help:      "The number of the published notifications by stage and result.",
+		}, []string{"stage", "result"}),
+
+		indexerErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "indexer_block_errors_total",
+			Help:      "The number of the blocks failed to index as the sender cannot be recovered, the indexer stops at the block until indexed.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.rpcRequests, m.rpcDuration, m.submissions, m.stageLatency,
+		m.upstreamErrors, m.upstreamDuration, m.notifications, m.indexerErrors,
+	)
+
+	return m
+}
+
+// Handler serves the metrics of the registry
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveCall records the result and duration of the RPC request, it is the rpc.CallObserver of the server
+func (m *Metrics) ObserveCall(method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	result := resultOK
+	if err != nil {
+		result = resultError
+	}
+	m.rpcRequests.WithLabelValues(method, result).Inc()
+	m.rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// the observers below are no-op on nil, so the server and the proxy work without metrics, e.g. in tests
+
+func (m *Metrics) observeSubmission(wait uint64, err error) {
+	if m == nil {
+		return
+	}
+
+	result := resultOK
+	if err != nil {
+		result = resultError
+	}
+	m.submissions.WithLabelValues(strconv.FormatUint(wait, 10), result).Inc()
+}
+
+func (m *Metrics) observeUpstreamError(op string) {
+	if m == nil {
+		return
+	}
+	m.upstreamErrors.WithLabelValues(op).Inc()
+}
+
+// observeUpstream records the duration of the call to the node started at start
+func (m *Metrics) observeUpstream(op string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.upstreamDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) observePublish(stage, result string) {
+	if m == nil {
+		return
+	}
+	m.notifications.WithLabelValues(stage, result).Inc()
+}
+
+func (m *Metrics) observeIndexerBlockError() {
+	if m == nil {
+		return
+	}
+	m.indexerErrors.Inc()
+}
+
+func (m *Metrics) observeStage(stage string, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.stageLatency.WithLabelValues(stage).Observe(latency.Seconds())
+}
+
+// registerServer registers the gauges of the queues of the server,
+// it fails if the metrics are registered by another server
+func (m *Metrics) registerServer(s *Server) error {
+	gauges := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_queue_length",
+			Help:      "The number of the messages waiting in the tx queue.",
+		}, func() float64 {
+			return float64(len(s.txChan))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "pending_confirmations",
+			Help:      "The number of the broadcast transactions waiting to be confirmed.",
+		}, func() float64 {
+			s.txs2ConfirmLock.Lock()
+			defer s.txs2ConfirmLock.Unlock()
+			return float64(len(s.txs2Confirm))
+		}),
+	}
+	for _, gauge := range gauges {
+		if err := m.registry.Register(gauge); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// txStages is the received and broadcast time of a submitted tx
+type txStages struct {
+	received  time.Time
+	broadcast time.Time // zero if not broadcast
+}
+
+// stageTimer records the received and broadcast time of the submitted txs,
+// at most the size of the txs are kept, so the txs never mined or forgotten do not leak
+type stageTimer struct {
+	lock    sync.Mutex
+	txs     *lru.Cache
+	metrics *Metrics
+}
+
+func newStageTimer(size int, metrics *Metrics) (*stageTimer, error) {
+	txs, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stageTimer{txs: txs, metrics: metrics}, nil
+}
+
+func (t *stageTimer) markReceived(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.txs.Add(hash, &txStages{received: time.Now()})
+}
+
+func (t *stageTimer) markBroadcast(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	v, ok := t.txs.Get(hash)
+	if !ok {
+		return
+	}
+	stages := v.(*txStages)
+	stages.broadcast = time.Now()
+	t.metrics.observeStage("broadcast", stages.broadcast.Sub(stages.received))
+}
+
+// markMined records the latency of the tx which has been broadcast by the server
+func (t *stageTimer) markMined(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	v, ok := t.txs.Peek(hash)
+	if !ok {
+		return
+	}
+	t.txs.Remove(hash)
+	if stages := v.(*txStages); !stages.broadcast.IsZero() {
+		t.metrics.observeStage("mined", time.Since(stages.received))
+	}
+}
+
+// forget removes the tx which failed to be broadcast
+func (t *stageTimer) forget(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.txs.Remove(hash)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStageTimer(t *testing.T) {
+	metrics := NewMetrics()
+	stages, err := newStageTimer(2, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the oldest tx is evicted once the timer is full
+	for i := byte(1); i <= 3; i++ {
+		stages.markReceived(common.Hash{i})
+	}
+	if stages.txs.Len() != 2 || stages.txs.Contains(common.Hash{1}) {
+		t.Fatalf("stage timer not bounded: %d", stages.txs.Len())
+	}
+
+	stages.markBroadcast(common.Hash{1})
+	stages.markBroadcast(common.Hash{2})
+	stages.markMined(common.Hash{2})
+	stages.forget(common.Hash{3})
+	if stages.txs.Len() != 0 {
+		t.Errorf("stage timer not empty: %d", stages.txs.Len())
+	}
+	if n := testutil.CollectAndCount(metrics.stageLatency); n != 2 {
+		t.Errorf("want broadcast and mined latency, got %d", n)
+	}
+}
+
+func TestMetricsPerServer(t *testing.T) {
+	// each server has its own registry, the gauges of another server never conflict
+	for i := 0; i < 2; i++ {
+		if err := NewMetrics().registerServer(&Server{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics := NewMetrics()
+	if err := metrics.registerServer(&Server{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.registerServer(&Server{}); err == nil {
+		t.Error("the metrics shared by the servers should fail")
+	}
+}
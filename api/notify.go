@@ -1,17 +1,25 @@
 package api
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
-	"strings"
+	"os"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/notification"
 	"github.com/sirupsen/logrus"
 )
 
+// NotifyConfig is the config of the MQTT publisher.
+// The client speaks MQTT 3.1.1, so the MQTT v5 properties are not available.
 type NotifyConfig struct {
 	Server      string
 	Username    string
@@ -19,12 +27,34 @@ type NotifyConfig struct {
 	ClientID    string
 	QoS         byte
 	PrefixTopic string // topic = <PrefixTopic>/<address>/<confirmedBlock>
+
+	// TLS, used if the server is ssl://, tls:// or wss://
+	CAFile             string // the CA of the broker, default is the system CAs
+	CertFile           string // the client certificate
+	KeyFile            string // the key of the client certificate
+	InsecureSkipVerify bool
+
+	StoreDir             string        // the dir of the outgoing messages of QoS>=1, kept across reconnects and restarts
+	MaxReconnectInterval time.Duration // the max wait between the reconnects
+	PublishTimeout       time.Duration // the wait of the publish confirmation before it is logged as timeout
+	RetainStatus         bool          // publish the retained last status of each tx to <PrefixTopic>/tx/<hash>
+	WatchConfirmations   uint64        // the confirmations of the transfers of the watched addresses before notified as confirmed
+	Encoding             string        // legacy, json or cbor, the json and cbor are the versioned notification envelope
 }
 
+// EncodingLegacy is the unversioned TransferTx json payload
+const EncodingLegacy = "legacy"
+
+const (
+	defaultMaxReconnectInterval = time.Minute
+	defaultPublishTimeout       = 10 * time.Second
+)
+
 func getPublishClient(n *NotifyConfig) (mqtt.Client, error) {
-	opts := mqtt.NewClientOptions().AddBroker(n.Server).SetClientID(n.ClientID)
-	opts.SetUsername(n.Username)
-	opts.SetPassword(n.Password)
+	opts, err := newClientOptions(n)
+	if err != nil {
+		return nil, err
+	}
 	c := mqtt.NewClient(opts)
 
 	if token := c.Connect(); token.Wait() && token.Error() != nil {
@@ -34,25 +64,220 @@ func getPublishClient(n *NotifyConfig) (mqtt.Client, error) {
 	return c, nil
 }
 
+// newClientOptions returns the options which reconnect automatically and resume the subscriptions and
+// the stored messages, the session is kept by the broker if the messages are stored on disk
+func newClientOptions(n *NotifyConfig) (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions().AddBroker(n.Server).SetClientID(n.ClientID)
+	opts.SetUsername(n.Username)
+	opts.SetPassword(n.Password)
+
+	maxReconnectInterval := n.MaxReconnectInterval
+	if maxReconnectInterval <= 0 {
+		maxReconnectInterval = defaultMaxReconnectInterval
+	}
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(maxReconnectInterval)
+	opts.SetResumeSubs(true)
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		log.Errorf("MQTT connection lost: %v, reconnecting...\n", err)
+	})
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		log.Infof("MQTT connected to %s\n", n.Server)
+	})
+
+	if n.StoreDir != "" && n.QoS > 0 {
+		if err := os.MkdirAll(n.StoreDir, 0700); err != nil {
+			return nil, err
+		}
+		opts.SetStore(mqtt.NewFileStore(n.StoreDir))
+		opts.SetCleanSession(false)
+	}
+
+	if n.CAFile != "" || n.CertFile != "" || n.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(n)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
+
+// NewSubscriberOptions returns the options to subscribe the broker of the config, e.g. by notification.NewSubscriber.
+// The client ID must differ from the publisher's, otherwise the broker disconnects one of them.
+func NewSubscriberOptions(n *NotifyConfig, clientID string) (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions().AddBroker(n.Server).SetClientID(clientID)
+	opts.SetUsername(n.Username)
+	opts.SetPassword(n.Password)
+
+	if n.CAFile != "" || n.CertFile != "" || n.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(n)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
+
+func newTLSConfig(n *NotifyConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: n.InsecureSkipVerify}
+
+	if n.CAFile != "" {
+		ca, err := ioutil.ReadFile(n.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", n.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if n.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(n.CertFile, n.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// notifyStages are the stages of the confirmed level of sendNotify
+var notifyStages = map[int64]string{
+	-1: notification.StageReceived,
+	0:  notification.StageBroadcast,
+	1:  notification.StageConfirmed,
+}
+
 func (s *Server) sendNotify(tx *TransferTx, confirmed int64) {
+	stage := notifyStages[confirmed]
 
-	payload, err := json.Marshal(tx)
+	legacy, err := s.marshalLegacy(tx)
 	if err != nil {
 		log.Error(err)
 		return
 	}
+	payload := legacy
+	if s.notify.Encoding != EncodingLegacy {
+		payload, err = notification.Encode(s.newEnvelope(tx, stage, ""), s.notify.Encoding)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
 	var topic string
 	if tx.To == nil {
-		topic = fmt.Sprintf("%s/ContractCreate", s.notify.PrefixTopic)
+		topic = notification.ContractCreationTopic(s.notify.PrefixTopic)
 	} else {
-		topic = fmt.Sprintf("%s/%s/%d", s.notify.PrefixTopic, strings.ToLower(tx.To.String()[2:]), confirmed)
+		topic = notification.AddressTopic(s.notify.PrefixTopic, *tx.To, stage)
 	}
 
 	log.WithFields(logrus.Fields{
 		"publish": topic,
-	}).Info(string(payload))
+	}).Info(string(legacy))
+
+	s.publish(topic, false, payload, stage)
+	s.publishStatus(tx, stage, "")
+}
+
+// marshalLegacy encodes the tx as the legacy json, with the NEW format addresses if enabled
+func (s *Server) marshalLegacy(tx *TransferTx) ([]byte, error) {
+	if !s.newAddress {
+		return json.Marshal(tx)
+	}
 
-	s.nc.Publish(topic, s.notify.QoS, false, string(payload))
+	ntx := *tx
+	ntx.fromNEW, ntx.toNEW = s.addressNEW(&tx.From), s.addressNEW(tx.To)
+	return json.Marshal(&ntx)
+}
+
+// newEnvelope returns the notification of the stage of the tx
+func (s *Server) newEnvelope(tx *TransferTx, stage, reason string) *notification.Envelope {
+	e := &notification.Envelope{
+		Version:   notification.Version,
+		Type:      notification.TypeTransfer,
+		Stage:     stage,
+		Timestamp: time.Now().Unix(),
+		ChainID:   s.networkID,
+		Tx: notification.Tx{
+			Hash:  tx.Hash,
+			From:  tx.From,
+			To:    tx.To,
+			Value: (*hexutil.Big)(tx.Value),
+			Data:  tx.Data,
+
+			FromNEW: s.addressNEW(&tx.From),
+			ToNEW:   s.addressNEW(tx.To),
+			Token:   tx.Token,
+		},
+		Receipt: tx.receipt,
+		Error:   reason,
+	}
+	if tx.Token != nil {
+		e.Type = notification.TypeTokenTransfer
+	} else if tx.To == nil {
+		e.Type = notification.TypeContractCreation
+	}
+
+	return e
+}
+
+// publishStatus publishes the retained last status of the tx if enabled,
+// so the subscribers get the current status once subscribed.
+// The status is the envelope, it is encoded as json if the legacy encoding is used.
+func (s *Server) publishStatus(tx *TransferTx, stage, reason string) {
+	if s.notify == nil || !s.notify.RetainStatus {
+		return
+	}
+
+	encoding := s.notify.Encoding
+	if encoding == EncodingLegacy {
+		encoding = notification.EncodingJSON
+	}
+	payload, err := notification.Encode(s.newEnvelope(tx, stage, reason), encoding)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	s.publish(notification.TxTopic(s.notify.PrefixTopic, tx.Hash), true, payload, "status")
+}
+
+// publish publishes the payload and waits the confirmation in background, the result is logged and counted.
+// The QoS 0 messages are dropped while reconnecting, the others are stored and sent after reconnected.
+func (s *Server) publish(topic string, retained bool, payload []byte, stage string) {
+	if s.notify.QoS == 0 && !s.nc.IsConnectionOpen() {
+		log.Warnf("Publish %s dropped: MQTT not connected\n", topic)
+		s.metrics.observePublish(stage, resultDropped)
+		return
+	}
+
+	token := s.nc.Publish(topic, s.notify.QoS, retained, payload)
+
+	timeout := s.notify.PublishTimeout
+	if timeout <= 0 {
+		timeout = defaultPublishTimeout
+	}
+	go func() {
+		if !token.WaitTimeout(timeout) {
+			log.Warnf("Publish %s not confirmed in %v\n", topic, timeout)
+			s.metrics.observePublish(stage, resultTimeout)
+			return
+		}
+		if err := token.Error(); err != nil {
+			log.Errorf("Publish %s error: %v\n", topic, err)
+			s.metrics.observePublish(stage, resultError)
+			return
+		}
+		s.metrics.observePublish(stage, resultOK)
+	}()
 }
 
 type TransferTx struct {
@@ -62,33 +287,48 @@ type TransferTx struct {
 	Hash        common.Hash     `json:"hash"`
 	Data        []byte          `json:"data"`
 	BlockNumber *big.Int        `json:"blockNumber"`
+	Token       *common.Address `json:"token"` // set if the token Transfer event, the From, To and Value are of the event
+
+	receipt *notification.Receipt // set if confirmed
+
+	fromNEW, toNEW string // the NEW format addresses of the legacy notification, set if enabled
 }
 
 // UnmarshalJSON decodes from json format to a TransferTx.
 func (c *TransferTx) UnmarshalJSON(data []byte) error {
 	type Tx struct {
-		From  common.Address  `json:"from"`
-		To    *common.Address `json:"to"`
-		Value string          `json:"value"`
-		Hash  common.Hash     `json:"hash"`
+		From        common.Address  `json:"from"`
+		To          *common.Address `json:"to"`
+		Value       *hexutil.Big    `json:"value"`
+		Hash        common.Hash     `json:"hash"`
+		Data        hexutil.Bytes   `json:"data"`
+		BlockNumber *hexutil.Big    `json:"blockNumber"`
+		Token       *common.Address `json:"token"`
 	}
 	var tx Tx
 	err := json.Unmarshal(data, &tx)
 	if err != nil {
 		return err
 	}
+	if tx.Value == nil {
+		return errors.New("missing required field 'value' for TransferTx")
+	}
 	c.From = tx.From
 	c.To = tx.To
-	value, err := hexutil.DecodeBig(tx.Value)
-	if err != nil {
-		return err
-	}
-	c.Value = value
+	c.Value = tx.Value.ToInt()
 	c.Hash = tx.Hash
+	c.Data = tx.Data
+	c.BlockNumber = (*big.Int)(tx.BlockNumber)
+	c.Token = tx.Token
 
 	return nil
 }
 
+func (c *TransferTx) setReceipt(r *rpcReceipt) {
+	c.BlockNumber = new(big.Int).SetUint64(uint64(r.BlockNumber))
+	c.receipt = r.notificationReceipt()
+}
+
 // MarshalJSON encodes to json format.
 func (c *TransferTx) MarshalJSON() ([]byte, error) {
 	type Tx struct {
@@ -98,6 +338,9 @@ func (c *TransferTx) MarshalJSON() ([]byte, error) {
 		Hash        common.Hash     `json:"hash"`
 		Data        hexutil.Bytes   `json:"data"`
 		BlockNumber *hexutil.Big    `json:"blockNumber"`
+		Token       *common.Address `json:"token,omitempty"`
+		FromNEW     string          `json:"fromNEW,omitempty"`
+		ToNEW       string          `json:"toNEW,omitempty"`
 	}
 
 	enc := &Tx{
@@ -107,6 +350,9 @@ func (c *TransferTx) MarshalJSON() ([]byte, error) {
 		Hash:        c.Hash,
 		Data:        c.Data,
 		BlockNumber: (*hexutil.Big)(c.BlockNumber),
+		Token:       c.Token,
+		FromNEW:     c.fromNEW,
+		ToNEW:       c.toNEW,
 	}
 
 	return json.Marshal(&enc)
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/newtonproject/newchain-api-express/rpc"
+)
+
+// proxyCacheSize is the max number of the immutable results in cache
+const proxyCacheSize = 4096
+
+// DefaultProxyMethods is the default allowlist of the methods forwarded to the node,
+// eth_sendRawTransaction is not included as it bypasses the tracking and notifications of newton_sendRawTransaction
+var DefaultProxyMethods = []string{
+	"eth_blockNumber",
+	"eth_call",
+	"eth_chainId",
+	"eth_estimateGas",
+	"eth_gasPrice",
+	"eth_getBalance",
+	"eth_getBlockByHash",
+	"eth_getBlockByNumber",
+	"eth_getBlockTransactionCountByHash",
+	"eth_getBlockTransactionCountByNumber",
+	"eth_getCode",
+	"eth_getLogs",
+	"eth_getStorageAt",
+	"eth_getTransactionByBlockHashAndIndex",
+	"eth_getTransactionByBlockNumberAndIndex",
+	"eth_getTransactionByHash",
+	"eth_getTransactionCount",
+	"eth_getTransactionReceipt",
+	"eth_syncing",
+	"net_version",
+	"net_listening",
+	"net_peerCount",
+	"web3_clientVersion",
+	"web3_sha3",
+}
+
+// immutableMethods are the methods whose non-null results never change for the same params
+var immutableMethods = map[string]bool{
+	"eth_chainId":                           true,
+	"eth_getBlockByHash":                    true,
+	"eth_getBlockTransactionCountByHash":    true,
+	"eth_getTransactionByBlockHashAndIndex": true,
+	"net_version":                           true,
+	"web3_sha3":                             true,
+}
+
+// ProxyConfig is the config of the proxy to the node
+type ProxyConfig struct {
+	Enabled bool
+	Methods []string // the allowlist of eth_*, net_* and web3_* methods
+}
+
+// Proxy forwards the allowed eth_*, net_* and web3_* methods to the node,
+// so the clients can use the server as the only endpoint.
+type Proxy struct {
+	client  *rpc.Client
+	methods map[string]bool
+	cache   *lru.Cache
+	metrics *Metrics
+}
+
+// NewProxy creates the proxy to the node of rpcURL, the calls to the node are observed by metrics if not nil
+func NewProxy(rpcURL string, config *ProxyConfig, metrics *Metrics) (*Proxy, error) {
+	client, err := rpc.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := lru.New(proxyCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = DefaultProxyMethods
+	}
+	p := &Proxy{
+		client:  client,
+		methods: make(map[string]bool),
+		cache:   cache,
+		metrics: metrics,
+	}
+	for _, method := range methods {
+		if namespace := strings.SplitN(method, "_", 2)[0]; namespace != "eth" && namespace != "net" && namespace != "web3" {
+			log.Warningf("Proxy method %s ignored, only eth_*, net_* and web3_*\n", method)
+			continue
+		}
+		p.methods[method] = true
+	}
+
+	return p, nil
+}
+
+// Proxies returns true if the method is in the allowlist
+func (p *Proxy) Proxies(method string) bool {
+	return p.methods[method]
+}
+
+// Call forwards the requests to the node in one batch, the immutable results are served from cache
+func (p *Proxy) Call(ctx context.Context, reqs []*rpc.ProxyRequest) {
+	batch := make([]rpc.BatchElem, 0, len(reqs))
+	pending := make([]*rpc.ProxyRequest, 0, len(reqs))
+	for _, req := range reqs {
+		if result, ok := p.cache.Get(cacheKey(req)); ok {
+			req.Result = result.(json.RawMessage)
+			continue
+		}
+
+		args, err := proxyArgs(req.Params)
+		if err != nil {
+			req.Error = err
+			continue
+		}
+		batch = append(batch, rpc.BatchElem{Method: req.Method, Args: args, Result: &req.Result})
+		pending = append(pending, req)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	err := p.client.BatchCallContext(ctx, batch)
+	p.metrics.observeUpstream("proxy", start)
+	if err != nil {
+		p.metrics.observeUpstreamError("proxy")
+		for _, req := range pending {
+			req.Error = err
+		}
+		return
+	}
+
+	for i, req := range pending {
+		if batch[i].Error != nil {
+			req.Error = batch[i].Error
+			continue
+		}
+		if immutableMethods[req.Method] && len(req.Result) > 0 && !bytes.Equal(req.Result, []byte("null")) {
+			p.cache.Add(cacheKey(req), req.Result)
+		}
+	}
+}
+
+// proxyArgs splits the positional params of the request
+func proxyArgs(params json.RawMessage) ([]interface{}, error) {
+	if len(params) == 0 {
+		return []interface{}{}, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(raw))
+	for i := range raw {
+		args[i] = raw[i]
+	}
+
+	return args, nil
+}
+
+func cacheKey(req *rpc.ProxyRequest) string {
+	if len(req.Params) == 0 {
+		return req.Method + "[]"
+	}
+
+	var params bytes.Buffer
+	if err := json.Compact(&params, req.Params); err != nil {
+		return req.Method + string(req.Params)
+	}
+	return req.Method + params.String()
+}
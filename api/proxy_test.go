@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/rpc"
+)
+
+// EthService is the upstream node of the test, rpc only registers exported types
+type EthService struct {
+	chainIDCalls int
+	sendCalls    int
+}
+
+func (u *EthService) ChainId() hexutil.Uint64 {
+	u.chainIDCalls++
+	return 1007
+}
+
+func (u *EthService) BlockNumber() hexutil.Uint64 {
+	return 100
+}
+
+func (u *EthService) Accounts() []string {
+	return nil
+}
+
+func (u *EthService) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	u.sendCalls++
+	if u.sendCalls > 1 {
+		return common.Hash{}, errors.New("known transaction: " + crypto.Keccak256Hash(data).Hex())
+	}
+	return crypto.Keccak256Hash(data), nil
+}
+
+func TestProxy(t *testing.T) {
+	upstream := new(EthService)
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	proxy, err := NewProxy(upstreamHTTP.URL, &ProxyConfig{Enabled: true, Methods: []string{"eth_chainId", "eth_blockNumber", "admin_peers"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy.Proxies("admin_peers") {
+		t.Error("admin_peers should be ignored")
+	}
+	server := rpc.NewServer()
+	server.SetProxy(proxy)
+	serverHTTP := httptest.NewServer(server)
+	defer serverHTTP.Close()
+
+	client, err := rpc.Dial(serverHTTP.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var chainID1, chainID2, number hexutil.Uint64
+	var accounts []string
+	batch := []rpc.BatchElem{
+		{Method: "eth_chainId", Args: []interface{}{}, Result: &chainID1},
+		{Method: "eth_blockNumber", Args: []interface{}{}, Result: &number},
+		{Method: "eth_accounts", Args: []interface{}{}, Result: &accounts},
+	}
+	if err := client.BatchCallContext(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Error != nil || chainID1 != 1007 {
+		t.Fatalf("eth_chainId: want 1007, got %d %v", chainID1, batch[0].Error)
+	}
+	if batch[1].Error != nil || number != 100 {
+		t.Fatalf("eth_blockNumber: want 100, got %d %v", number, batch[1].Error)
+	}
+	if batch[2].Error == nil {
+		t.Fatal("eth_accounts: want method not found, got nil")
+	}
+
+	// the immutable result is served from cache
+	if err := client.CallArrayContext(context.Background(), &chainID2, "eth_chainId"); err != nil {
+		t.Fatal(err)
+	}
+	if chainID2 != 1007 || upstream.chainIDCalls != 1 {
+		t.Fatalf("cached eth_chainId: want 1007 with 1 upstream call, got %d with %d", chainID2, upstream.chainIDCalls)
+	}
+}
@@ -2,50 +2,69 @@ package api
 
 import (
 	"context"
-	"crypto/elliptic"
 	"errors"
 	"fmt"
 	"math/big"
 	"os"
 	"sync"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/newtonproject/newchain-api-express/params"
+	"github.com/newtonproject/newchain-api-express/rpc"
+	"github.com/newtonproject/newchain-api-express/utils"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
-var log *logrus.Logger
+var log = logrus.New()
 
 type Config struct {
 	CandidateFee uint64
 	VoteFee      uint64
 }
 
-var (
-	secp256r1N     = elliptic.P256().Params().N
-	secp256r1halfN = new(big.Int).Div(secp256r1N, big.NewInt(2))
-)
-
 // Server is used to implement forceproto.ForceServer.
 type Server struct {
 	logger *logrus.Logger
 
-	rpcURL    string
-	networkID uint64
-	gasPrice  *big.Int
+	rpcURL            string
+	networkID         uint64
+	gasPrice          *big.Int
+	gasPriceUpdatedAt time.Time
+	gasPriceLock      sync.RWMutex
+
+	health *HealthConfig
+
+	newAddress bool // return the NEW format addresses alongside hex
 
 	txChan          chan interface{}
 	txs2Confirm     []*TransferTx
 	txs2ConfirmLock sync.Mutex
 
+	// store
+	store          *store
+	submissionLock sync.Mutex
+
+	indexer *indexer
+
+	receiptCache *lru.Cache
+
+	metrics *Metrics
+	stages  *stageTimer
+
+	// watched addresses
+	watched     map[common.Address]struct{}
+	watchedLock sync.RWMutex
+
 	// notify
 	notify *NotifyConfig
 	nc     mqtt.Client
@@ -60,9 +79,8 @@ func logRequest(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo
 	return handler(ctx, req)
 }
 
-// NewServer listen and server
-func NewServer(rpcURL string, notify *NotifyConfig) (*Server, error) {
-	log = logrus.New()
+// NewServer listen and server, the metrics are registered in the registry of metrics, which is not shared with other servers
+func NewServer(rpcURL string, notify *NotifyConfig, dataDir string, indexerConfig *IndexerConfig, healthConfig *HealthConfig, newAddress bool, metrics *Metrics) (*Server, error) {
 	log.Out = os.Stdout
 
 	if notify == nil {
@@ -92,29 +110,66 @@ func NewServer(rpcURL string, notify *NotifyConfig) (*Server, error) {
 		return nil, err
 	}
 
+	st, err := openStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	receiptCache, err := lru.New(receiptCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	stages, err := newStageTimer(stageTimerSize, metrics)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
 		rpcURL:      rpcURL,
-		gasPrice:    gasPrice,
 		networkID:   networkID.Uint64(),
 		txChan:      make(chan interface{}, 1024),
 		txs2Confirm: make([]*TransferTx, 0),
 		notify:      notify,
 		nc:          nc,
+		store:       st,
+
+		receiptCache: receiptCache,
+		metrics:      metrics,
+		stages:       stages,
+
+		gasPrice:          gasPrice,
+		gasPriceUpdatedAt: time.Now(),
+		health:            healthConfig,
+
+		newAddress: newAddress,
+	}
+	if err := metrics.registerServer(server); err != nil {
+		return nil, err
 	}
 
-	go func() {
-		// TODO: update gasPrice hourly
-	}()
+	if err := server.loadWatched(); err != nil {
+		return nil, err
+	}
+
+	if indexerConfig != nil && indexerConfig.Enabled {
+		server.indexer = newIndexer(rpcURL, server.networkID, indexerConfig, st, metrics)
+		go server.indexer.run()
+	}
 
 	go server.handleTxs()
 	go server.handleTxs2Confirm()
+	go server.handleWatched()
+	go server.updateGasPrice()
 
 	return server, nil
 }
 
-// GetBaseInfoArgs address
+// GetBaseInfoArgs address in hex or NEW format and the optional block, default is latest.
+// Only one of BlockNumber and BlockHash can be set.
 type GetBaseInfoArgs struct {
-	Address common.Address `json:"address"`
+	Address     utils.Address    `json:"address"`
+	BlockNumber *rpc.BlockNumber `json:"blockNumber"`
+	BlockHash   *common.Hash     `json:"blockHash"`
 }
 
 type BaseInfo struct {
@@ -123,17 +178,27 @@ type BaseInfo struct {
 	GasPrice     *hexutil.Big    `json:"gasPrice"`
 	NetworkID    uint64          `json:"networkID"`
 	Balance      *hexutil.Big    `json:"balance"`
+	BlockNumber  *hexutil.Big    `json:"blockNumber"`
+	BlockHash    common.Hash     `json:"blockHash"`
 }
 
 func (s *Server) GetBaseInfo(ctx context.Context, args GetBaseInfoArgs) (*BaseInfo, error) {
-	address := args.Address
+	if err := s.checkAddresses(&args.Address); err != nil {
+		return nil, err
+	}
+	address := args.Address.Address
 
 	client, err := ethclient.Dial(s.rpcURL)
 	if err != nil {
 		return nil, err
 	}
 
-	nonceLatest, err := client.NonceAt(ctx, address, nil)
+	header, err := resolveBlock(ctx, client, args.BlockNumber, args.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceLatest, err := client.NonceAt(ctx, address, header.Number)
 	if err != nil {
 		return nil, err
 	}
@@ -143,213 +208,428 @@ func (s *Server) GetBaseInfo(ctx context.Context, args GetBaseInfoArgs) (*BaseIn
 		return nil, err
 	}
 
-	balance, err := client.BalanceAt(ctx, address, nil)
+	balance, err := client.BalanceAt(ctx, address, header.Number)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkCanonical(ctx, client, header); err != nil {
+		return nil, err
+	}
+
+	gasPrice, _ := s.getGasPrice()
+
 	return &BaseInfo{
-		GasPrice:     (*hexutil.Big)(s.gasPrice),
+		GasPrice:     (*hexutil.Big)(gasPrice),
 		NetworkID:    s.networkID,
 		NonceLatest:  (*hexutil.Uint64)(&nonceLatest),
 		NoncePending: (*hexutil.Uint64)(&noncePending),
 		Balance:      (*hexutil.Big)(balance),
+		BlockNumber:  (*hexutil.Big)(header.Number),
+		BlockHash:    header.Hash(),
 	}, nil
 }
 
-// SendRawTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
-type SendRawTxArgs struct {
-	Tx   hexutil.Bytes `json:"tx"`
-	Wait uint64        `json:"wait"`
+// resolveBlock returns the header of the block specified by number or hash, default is latest
+func resolveBlock(ctx context.Context, client *ethclient.Client, number *rpc.BlockNumber, hash *common.Hash) (*types.Header, error) {
+	if number != nil && hash != nil {
+		return nil, errors.New("only one of blockNumber and blockHash can be set")
+	}
+	if hash != nil {
+		return client.HeaderByHash(ctx, *hash)
+	}
+	if number == nil || *number == rpc.LatestBlockNumber {
+		return client.HeaderByNumber(ctx, nil)
+	}
+	if *number == rpc.PendingBlockNumber {
+		return nil, errors.New("pending block is not supported")
+	}
+
+	return client.HeaderByNumber(ctx, big.NewInt(number.Int64()))
 }
 
-func (s *Server) SendRawTransaction(ctx context.Context, args SendRawTxArgs) (common.Hash, error) {
-	wait := args.Wait
-	if wait != params.LevelWaitBroadcast && wait != params.LevelWaitConfirmed {
-		wait = params.LevelNoWait
+// checkCanonical checks the block is still in the canonical chain after the queries
+func checkCanonical(ctx context.Context, client *ethclient.Client, header *types.Header) error {
+	canonical, err := client.HeaderByNumber(ctx, header.Number)
+	if err != nil {
+		return err
+	}
+	if canonical.Hash() != header.Hash() {
+		return fmt.Errorf("block %s reorganized, please retry", header.Hash().String())
 	}
 
-	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(args.Tx, tx); err != nil {
-		return common.Hash{}, err
+	return nil
+}
+
+// maxBaseInfosAddresses is the max number of addresses in one newton_getBaseInfos call
+const maxBaseInfosAddresses = 1000
+
+// GetBaseInfosArgs addresses and the optional block, default is latest.
+// Only one of BlockNumber and BlockHash can be set.
+type GetBaseInfosArgs struct {
+	Addresses   []utils.Address  `json:"addresses"`
+	BlockNumber *rpc.BlockNumber `json:"blockNumber"`
+	BlockHash   *common.Hash     `json:"blockHash"`
+}
+
+// AddressInfo is the nonces and balance of the address
+type AddressInfo struct {
+	Address      common.Address  `json:"address"`
+	AddressNEW   string          `json:"addressNEW,omitempty"`
+	NonceLatest  *hexutil.Uint64 `json:"nonceLatest,omitempty"`
+	NoncePending *hexutil.Uint64 `json:"noncePending,omitempty"`
+	Balance      *hexutil.Big    `json:"balance,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+type BaseInfos struct {
+	GasPrice    *hexutil.Big   `json:"gasPrice"`
+	NetworkID   uint64         `json:"networkID"`
+	BlockNumber *hexutil.Big   `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Infos       []*AddressInfo `json:"infos"`
+}
+
+// GetBaseInfos returns the base info of the addresses,
+// nonces and balances are fetched with upstream batch calls.
+func (s *Server) GetBaseInfos(ctx context.Context, args GetBaseInfosArgs) (*BaseInfos, error) {
+	if len(args.Addresses) > maxBaseInfosAddresses {
+		return nil, fmt.Errorf("too many addresses, want at most %d", maxBaseInfosAddresses)
+	}
+	for i := range args.Addresses {
+		if err := s.checkAddresses(&args.Addresses[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	signer := types.NewEIP155Signer(big.NewInt(0).SetUint64(s.networkID))
-	from, err := signer.Sender(tx)
+	ethClient, err := ethclient.Dial(s.rpcURL)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
-	// notify received
-	s.txChan <- txNotifyReceived{tx: &TransferTx{
-		From:  from,
-		To:    tx.To(),
-		Value: tx.Value(),
-		Hash:  tx.Hash(),
-		Data:  tx.Data(),
-	}}
+	header, err := resolveBlock(ctx, ethClient, args.BlockNumber, args.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	block := hexutil.EncodeBig(header.Number)
 
-	if wait == params.LevelNoWait {
-		s.txChan <- tx2Broadcast{tx: tx, from: from}
-		return tx.Hash(), nil
+	client, err := rpc.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	n := len(args.Addresses)
+	nonceLatest := make([]hexutil.Uint64, n)
+	noncePending := make([]hexutil.Uint64, n)
+	balance := make([]hexutil.Big, n)
+	batch := make([]rpc.BatchElem, 0, 3*n)
+	for i := range args.Addresses {
+		address := args.Addresses[i].Address
+		batch = append(batch,
+			rpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{address, block}, Result: &nonceLatest[i]},
+			rpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{address, "pending"}, Result: &noncePending[i]},
+			rpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{address, block}, Result: &balance[i]})
+	}
+	if n > 0 {
+		if err := client.BatchCallContext(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkCanonical(ctx, ethClient, header); err != nil {
+		return nil, err
+	}
+
+	infos := make([]*AddressInfo, n)
+	for i := range args.Addresses {
+		address := args.Addresses[i].Address
+		info := &AddressInfo{Address: address, AddressNEW: s.addressNEW(&address)}
+		for _, elem := range batch[3*i : 3*i+3] {
+			if elem.Error != nil {
+				info.Error = elem.Error.Error()
+				break
+			}
+		}
+		if info.Error == "" {
+			info.NonceLatest = &nonceLatest[i]
+			info.NoncePending = &noncePending[i]
+			info.Balance = &balance[i]
+		}
+		infos[i] = info
+	}
+
+	gasPrice, _ := s.getGasPrice()
+
+	return &BaseInfos{
+		GasPrice:    (*hexutil.Big)(gasPrice),
+		NetworkID:   s.networkID,
+		BlockNumber: (*hexutil.Big)(header.Number),
+		BlockHash:   header.Hash(),
+		Infos:       infos,
+	}, nil
+}
+
+// BuildTxArgs represents the arguments to build an unsigned transaction, the addresses are in hex or NEW format.
+type BuildTxArgs struct {
+	From  utils.Address   `json:"from"`
+	To    *utils.Address  `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+	Data  hexutil.Bytes   `json:"data"`
+	Gas   *hexutil.Uint64 `json:"gas"`
+}
+
+// UnsignedTx is the unsigned transaction and the hash to be signed by the client
+type UnsignedTx struct {
+	Tx        hexutil.Bytes  `json:"tx"`
+	Hash      common.Hash    `json:"hash"`
+	Nonce     hexutil.Uint64 `json:"nonce"`
+	GasPrice  *hexutil.Big   `json:"gasPrice"`
+	Gas       hexutil.Uint64 `json:"gas"`
+	NetworkID uint64         `json:"networkID"`
+}
+
+// BuildTransaction fills nonce, gas price, gas limit and chain ID of the transaction,
+// returns the unsigned RLP and the signing hash.
+// The client only need to sign the hash and call newton_sendTransaction.
+func (s *Server) BuildTransaction(ctx context.Context, args BuildTxArgs) (*UnsignedTx, error) {
+	if err := s.checkAddresses(&args.From, args.To); err != nil {
+		return nil, err
+	}
+	from, to := args.From.Address, args.To.AddressPtr()
+	if args.To == nil && len(args.Data) == 0 {
+		return nil, errors.New("contract creation without data")
+	}
+	value := big.NewInt(0)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	if value.Sign() < 0 {
+		return nil, errors.New("value cannot be negative")
 	}
 
 	client, err := ethclient.Dial(s.rpcURL)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
-	err = client.SendTransaction(ctx, tx)
+	nonce, err := client.PendingNonceAt(ctx, from)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
-	// notify broadcast
-	s.txChan <- txNotifyBroadcast{tx: &TransferTx{
-		From:  from,
-		To:    tx.To(),
-		Value: tx.Value(),
-		Hash:  tx.Hash(),
-		Data:  tx.Data(),
-	}}
+	gasPrice, _ := s.getGasPrice()
+
+	var gas uint64
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	} else {
+		gas, err = client.EstimateGas(ctx, ethereum.CallMsg{
+			From:     from,
+			To:       to,
+			GasPrice: gasPrice,
+			Value:    value,
+			Data:     args.Data,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	if wait == params.LevelWaitBroadcast {
-		s.txChan <- tx2Confirm{tx: tx, from: from}
-		return tx.Hash(), nil
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(nonce, value, gas, gasPrice, args.Data)
+	} else {
+		tx = types.NewTransaction(nonce, *to, value, gas, gasPrice, args.Data)
 	}
 
-	_, err = bind.WaitMined(ctx, client, tx)
+	rlpTx, err := rlp.EncodeToBytes(tx)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
-	// notify confirmed
-	s.txChan <- txNotifyConfirmed{tx: &TransferTx{
-		From:  from,
-		To:    tx.To(),
-		Value: tx.Value(),
-		Hash:  tx.Hash(),
-		Data:  tx.Data(),
-	}}
+	signer := types.NewEIP155Signer(big.NewInt(0).SetUint64(s.networkID))
 
-	return tx.Hash(), err
+	return &UnsignedTx{
+		Tx:        rlpTx,
+		Hash:      signer.Hash(tx),
+		Nonce:     hexutil.Uint64(nonce),
+		GasPrice:  (*hexutil.Big)(gasPrice),
+		Gas:       hexutil.Uint64(gas),
+		NetworkID: s.networkID,
+	}, nil
 }
 
-// SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
+// SendRawTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
+type SendRawTxArgs struct {
+	Tx             hexutil.Bytes `json:"tx"`
+	Wait           uint64        `json:"wait"`
+	IdempotencyKey string        `json:"idempotencyKey"`
+}
+
+func (s *Server) SendRawTransaction(ctx context.Context, args SendRawTxArgs) (common.Hash, error) {
+	tx, from, err := s.decodeRawTx(args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return s.submitTrackedTx(ctx, tx, from, checkWait(args.Wait), args.IdempotencyKey)
+}
+
+// SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool,
+// the from address is in hex or NEW format.
 type SendTxArgs struct {
-	From      common.Address `json:"from"`
-	Tx        hexutil.Bytes  `json:"tx"`
-	Signature hexutil.Bytes  `json:"signature"`
-	Wait      uint64         `json:"wait"`
+	From           utils.Address `json:"from"`
+	Tx             hexutil.Bytes `json:"tx"`
+	Signature      hexutil.Bytes `json:"signature"`
+	Wait           uint64        `json:"wait"`
+	IdempotencyKey string        `json:"idempotencyKey"`
 }
 
 func (s *Server) SendTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
-	wait := args.Wait
+	signTx, err := s.assembleTx(args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return s.submitTrackedTx(ctx, signTx, args.From.Address, checkWait(args.Wait), args.IdempotencyKey)
+}
+
+func checkWait(wait uint64) uint64 {
 	if wait != params.LevelWaitBroadcast && wait != params.LevelWaitConfirmed {
-		wait = params.LevelNoWait
+		return params.LevelNoWait
 	}
+	return wait
+}
 
-	from := args.From
+// decodeRawTx decodes the signed raw tx and recovers the sender
+func (s *Server) decodeRawTx(args SendRawTxArgs) (*types.Transaction, common.Address, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(args.Tx, tx); err != nil {
+		return nil, common.Address{}, err
+	}
+
+	signer := types.NewEIP155Signer(big.NewInt(0).SetUint64(s.networkID))
+	from, err := signer.Sender(tx)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	return tx, from, nil
+}
+
+// assembleTx computes the recovery ID of the signature and assembles the signed tx
+func (s *Server) assembleTx(args SendTxArgs) (*types.Transaction, error) {
+	if err := s.checkAddresses(&args.From); err != nil {
+		return nil, err
+	}
+	from := args.From.Address
 
 	rlpTx := []byte(args.Tx)
 	sign := []byte(args.Signature)
 	if len(sign) != 64 {
-		return common.Hash{}, errors.New("invalid signature length")
+		return nil, errors.New("invalid signature length")
 	}
 
 	var tx *types.Transaction
 	err := rlp.DecodeBytes(rlpTx, &tx)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
 	signer := types.NewEIP155Signer(big.NewInt(0).SetUint64(s.networkID))
 	sHash := signer.Hash(tx)
 
-	// sign
-	signature := make([]byte, 32*2+1)
-	copy(signature[:32], sign) // r
-
-	// check s
-	// update upper range of s values (ECDSA malleability)
-	// see discussion in secp256k1/libsecp256k1/include/secp256k1.h
-	signS := big.NewInt(0).SetBytes(sign[32:64])
-	if signS.Cmp(secp256r1halfN) > 0 {
-		signS = new(big.Int).Sub(secp256r1N, signS)
-	}
-	sBytes := signS.Bytes()
-	copy(signature[64-len(sBytes):], sBytes) // s
-
-	recId := byte(0)
-	for recId = 0; recId < 4; recId++ {
-		signature[64] = recId // v
-		pk, _ := crypto.SigToPub(sHash.Bytes(), signature)
-		if pk != nil && crypto.PubkeyToAddress(*pk) == from {
-			break
-		}
-	}
-	if recId == 4 {
-		return common.Hash{}, fmt.Errorf("invalid signature, could not construct a recoverable key")
-	}
-
-	signTx, err := tx.WithSignature(signer, signature)
+	signature, err := utils.RecoverSignature(sHash.Bytes(), sign, from)
 	if err != nil {
-		return common.Hash{}, err
+		return nil, err
 	}
 
-	// ok, tx is ok
+	return tx.WithSignature(signer, signature)
+}
 
-	// notify received
-	s.txChan <- txNotifyReceived{tx: &TransferTx{
+func newTransferTx(tx *types.Transaction, from common.Address) *TransferTx {
+	return &TransferTx{
 		From:  from,
-		To:    signTx.To(),
-		Value: signTx.Value(),
-		Hash:  signTx.Hash(),
-		Data:  signTx.Data(),
-	}}
+		To:    tx.To(),
+		Value: tx.Value(),
+		Hash:  tx.Hash(),
+		Data:  tx.Data(),
+	}
+}
+
+// submitTx notifies the tx received, then broadcasts the tx and waits as the wait level
+func (s *Server) submitTx(ctx context.Context, tx *types.Transaction, from common.Address, wait uint64) (common.Hash, error) {
+	// notify received
+	s.txChan <- txNotifyReceived{tx: newTransferTx(tx, from)}
 
 	if wait == params.LevelNoWait {
-		s.txChan <- tx2Broadcast{tx: signTx, from: from}
-		return signTx.Hash(), nil
+		s.txChan <- tx2Broadcast{tx: tx, from: from}
+		return tx.Hash(), nil
 	}
 
 	client, err := ethclient.Dial(s.rpcURL)
 	if err != nil {
 		return common.Hash{}, err
 	}
-	err = client.SendTransaction(ctx, signTx)
-	if err != nil {
+
+	if err := s.broadcastTx(ctx, client, tx, from); err != nil {
 		return common.Hash{}, err
 	}
 
-	// notify broadcast
-	s.txChan <- txNotifyBroadcast{tx: &TransferTx{
-		From:  from,
-		To:    signTx.To(),
-		Value: signTx.Value(),
-		Hash:  signTx.Hash(),
-		Data:  signTx.Data(),
-	}}
-
 	if wait == params.LevelWaitBroadcast {
-		s.txChan <- tx2Confirm{tx: signTx, from: from}
-		return signTx.Hash(), nil
+		s.txChan <- tx2Confirm{tx: tx, from: from}
+		return tx.Hash(), nil
 	}
 
-	_, err = bind.WaitMined(ctx, client, signTx)
-	if err != nil {
+	if err := s.waitConfirmed(ctx, client, tx, from); err != nil {
 		return common.Hash{}, err
 	}
 
-	// notify confirmed
-	s.txChan <- txNotifyConfirmed{tx: &TransferTx{
-		From:  from,
-		To:    signTx.To(),
-		Value: signTx.Value(),
-		Hash:  signTx.Hash(),
-		Data:  signTx.Data(),
-	}}
+	return tx.Hash(), nil
+}
+
+// broadcastTx sends the tx to NewChain RPC and notifies broadcast.
+// The tx already known by the node is treated as broadcast.
+func (s *Server) broadcastTx(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address) error {
+	start := time.Now()
+	err := client.SendTransaction(ctx, tx)
+	s.metrics.observeUpstream("sendTransaction", start)
+	if err != nil && !isKnownTxError(err) {
+		s.metrics.observeUpstreamError("sendTransaction")
+		return err
+	}
 
-	return signTx.Hash(), err
+	// notify broadcast
+	s.txChan <- txNotifyBroadcast{tx: newTransferTx(tx, from)}
+
+	return nil
+}
+
+// waitMinedError is the error of waiting the broadcast tx to be mined, e.g. timeout,
+// the tx is still pending so it is not failed
+type waitMinedError struct {
+	err error
+}
+
+func (e *waitMinedError) Error() string {
+	return e.err.Error()
+}
+
+// waitConfirmed waits the tx to be mined and notifies confirmed.
+// If the wait fails, the tx is queued to be confirmed later and the error is a *waitMinedError.
+func (s *Server) waitConfirmed(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address) error {
+	_, err := bind.WaitMined(ctx, client, tx)
+	if err != nil {
+		s.metrics.observeUpstreamError("waitMined")
+		s.txChan <- tx2Confirm{tx: tx, from: from}
+		return &waitMinedError{err: err}
+	}
+
+	// notify confirmed
+	transferTx := newTransferTx(tx, from)
+	s.fillReceipt(ctx, transferTx)
+	s.txChan <- txNotifyConfirmed{tx: transferTx}
 
+	return nil
 }
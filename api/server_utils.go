@@ -1 +1,24 @@
 package api
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+// checkAddresses returns error if any of the NEW format addresses is of another chain
+func (s *Server) checkAddresses(addresses ...*utils.Address) error {
+	for _, address := range addresses {
+		if err := address.CheckChainID(s.networkID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addressNEW returns the NEW format of the address if enabled, empty if disabled or nil
+func (s *Server) addressNEW(address *common.Address) string {
+	if !s.newAddress || address == nil {
+		return ""
+	}
+	return utils.AddressToNew(s.networkID, *address)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+// VerifySignatureArgs is the signed message or EIP-712 typed data, the signature and the expected signer
+type VerifySignatureArgs struct {
+	Message   *string          `json:"message"`   // the text signed as personal_sign
+	TypedData *utils.TypedData `json:"typedData"` // the EIP-712 typed data
+	Signature hexutil.Bytes    `json:"signature"` // 64 bytes [R || S] or 65 bytes [R || S || V]
+	Address   *utils.Address   `json:"address"`   // required for the 64 bytes signature
+}
+
+// VerifySignatureResult is the recovered signer
+type VerifySignatureResult struct {
+	Signer    *common.Address `json:"signer"`
+	SignerNEW string          `json:"signerNEW,omitempty"`
+	Valid     bool            `json:"valid"`
+}
+
+// VerifySignature recovers the signer of the message or typed data.
+// With the address, the recovery ID is searched as newton_sendTransaction, so V of the signature is ignored,
+// and valid is true if signed by the address. Without the address, the signer is recovered by V.
+func (s *Server) VerifySignature(ctx context.Context, args VerifySignatureArgs) (*VerifySignatureResult, error) {
+	var hash []byte
+	switch {
+	case args.Message != nil && args.TypedData != nil:
+		return nil, errors.New("only one of message and typedData can be set")
+	case args.Message != nil:
+		hash = utils.TextHash([]byte(*args.Message))
+	case args.TypedData != nil:
+		var err error
+		if hash, err = args.TypedData.Hash(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("message or typedData is required")
+	}
+
+	sign := []byte(args.Signature)
+	if len(sign) != 64 && len(sign) != 65 {
+		return nil, utils.ErrInvalidSignatureLength
+	}
+
+	if args.Address == nil {
+		if len(sign) == 64 {
+			return nil, errors.New("address is required for the 64 bytes signature")
+		}
+		signer, err := utils.RecoverSigner(hash, sign)
+		if err != nil {
+			return nil, err
+		}
+		return &VerifySignatureResult{Signer: &signer, SignerNEW: s.addressNEW(&signer), Valid: true}, nil
+	}
+
+	if err := s.checkAddresses(args.Address); err != nil {
+		return nil, err
+	}
+	address := args.Address.Address
+	if _, err := utils.RecoverSignature(hash, sign, address); err != nil {
+		if err != utils.ErrUnrecoverableSignature {
+			return nil, err
+		}
+
+		// report the signer by V if possible
+		result := &VerifySignatureResult{}
+		if len(sign) == 65 {
+			if signer, err := utils.RecoverSigner(hash, sign); err == nil {
+				result.Signer, result.SignerNEW = &signer, s.addressNEW(&signer)
+			}
+		}
+		return result, nil
+	}
+
+	return &VerifySignatureResult{Signer: &address, SignerNEW: s.addressNEW(&address), Valid: true}, nil
+}
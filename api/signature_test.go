@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+func TestVerifySignature(t *testing.T) {
+	s := &Server{networkID: 1007}
+	ctx := context.Background()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	message := "I own this address"
+	sign, err := crypto.Sign(utils.TextHash([]byte(message)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := utils.RecoverSignature(utils.TextHash([]byte(message)), sign, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature[64] += 27
+
+	// 65 bytes signature without the address
+	result, err := s.VerifySignature(ctx, VerifySignatureArgs{Message: &message, Signature: signature})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Signer == nil || *result.Signer != address {
+		t.Fatalf("want signer %s, got %+v", address.String(), result)
+	}
+
+	// 64 bytes signature with the address
+	args := VerifySignatureArgs{Message: &message, Signature: signature[:64], Address: &utils.Address{Address: address}}
+	if result, err := s.VerifySignature(ctx, args); err != nil || !result.Valid {
+		t.Fatalf("want valid, got %+v %v", result, err)
+	}
+	other := "I own another address"
+	args.Message = &other
+	if result, err := s.VerifySignature(ctx, args); err != nil || result.Valid {
+		t.Fatalf("want invalid, got %+v %v", result, err)
+	}
+
+	args.Address = nil
+	if _, err := s.VerifySignature(ctx, args); err == nil {
+		t.Fatal("64 bytes signature without address accepted")
+	}
+}
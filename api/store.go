@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// key prefix of the store
+var (
+	prefixTxRecord       = []byte("tx-")    // tx-<hash> -> TxRecord
+	prefixIdempotencyKey = []byte("key-")   // key-<idempotencyKey> -> hash
+	prefixHistory        = []byte("his-")   // his-<address><blockNumber><txIndex><seq><direction> -> HistoryTx
+	prefixWatched        = []byte("watch-") // watch-<address> -> true
+	keyIndexerNext       = []byte("indexer-next")
+	keyScannerNext       = []byte("scanner-next")     // the next block to notify as confirmed
+	keyScannerReceived   = []byte("scanner-received") // the next block to notify as received
+)
+
+// store is the embedded database of the server, values are encoded in json
+type store struct {
+	db *leveldb.DB
+}
+
+func openStore(path string) (*store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store{db: db}, nil
+}
+
+func (st *store) close() error {
+	return st.db.Close()
+}
+
+// get decodes the value of key into v, returns false if the key not found
+func (st *store) get(key []byte, v interface{}) (bool, error) {
+	data, err := st.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, v)
+}
+
+func (st *store) put(key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return st.db.Put(key, data, nil)
+}
+
+// write applies the batch atomically
+func (st *store) write(batch *leveldb.Batch) error {
+	return st.db.Write(batch, nil)
+}
+
+// batchPut encodes v into the batch
+func batchPut(batch *leveldb.Batch, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	batch.Put(key, data)
+
+	return nil
+}
+
+// iterateRange calls fn with the key and value of each entry in [start, limit),
+// in reverse order if reverse is true, stops if fn returns false
+func (st *store) iterateRange(start, limit []byte, reverse bool, fn func(key, value []byte) bool) error {
+	iter := st.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+	defer iter.Release()
+
+	next, ok := iter.Next, iter.First()
+	if reverse {
+		next, ok = iter.Prev, iter.Last()
+	}
+	for ; ok; ok = next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+// iteratePrefix calls fn with the key and value of each entry with the prefix, stops if fn returns false
+func (st *store) iteratePrefix(prefix []byte, fn func(key, value []byte) bool) error {
+	iter := st.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func (st *store) delete(key []byte) error {
+	return st.db.Delete(key, nil)
+}
+
+func storeKey(prefix []byte, key []byte) []byte {
+	return append(append(make([]byte, 0, len(prefix)+len(key)), prefix...), key...)
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/newtonproject/newchain-api-express/params"
+)
+
+// status of the submitted tx
+const (
+	StatusReceived  = "received"
+	StatusBroadcast = "broadcast"
+	StatusConfirmed = "confirmed"
+	StatusFailed    = "failed"
+)
+
+var statusRank = map[string]int{
+	StatusReceived:  0,
+	StatusBroadcast: 1,
+	StatusConfirmed: 2,
+}
+
+// TxRecord is the tracked state of the submitted tx
+type TxRecord struct {
+	Hash           common.Hash     `json:"hash"`
+	From           common.Address  `json:"from"`
+	To             *common.Address `json:"to"`
+	FromNEW        string          `json:"fromNEW,omitempty"` // set in the response if enabled
+	ToNEW          string          `json:"toNEW,omitempty"`
+	Nonce          uint64          `json:"nonce"`
+	Status         string          `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	UpdatedAt      int64           `json:"updatedAt"`
+}
+
+// prepareSubmission checks the idempotency key and the tracked state of the tx.
+// It returns the record if the tx has been submitted and not failed,
+// otherwise tracks the tx as received and returns nil.
+func (s *Server) prepareSubmission(tx *types.Transaction, from common.Address, key string) (*TxRecord, error) {
+	s.submissionLock.Lock()
+	defer s.submissionLock.Unlock()
+
+	hash := tx.Hash()
+	if key != "" {
+		var keyHash common.Hash
+		found, err := s.store.get(storeKey(prefixIdempotencyKey, []byte(key)), &keyHash)
+		if err != nil {
+			return nil, err
+		}
+		if found && keyHash != hash {
+			return nil, ErrorCode(errDuplicateSerialNo)
+		}
+	}
+
+	record := new(TxRecord)
+	found, err := s.store.get(storeKey(prefixTxRecord, hash.Bytes()), record)
+	if err != nil {
+		return nil, err
+	}
+	if found && record.Status != StatusFailed {
+		if key != "" && record.IdempotencyKey == "" {
+			record.IdempotencyKey = key
+			if err := s.store.put(storeKey(prefixTxRecord, hash.Bytes()), record); err != nil {
+				return nil, err
+			}
+			if err := s.store.put(storeKey(prefixIdempotencyKey, []byte(key)), hash); err != nil {
+				return nil, err
+			}
+		}
+		return record, nil
+	}
+
+	record = &TxRecord{
+		Hash:           hash,
+		From:           from,
+		To:             tx.To(),
+		Nonce:          tx.Nonce(),
+		Status:         StatusReceived,
+		IdempotencyKey: key,
+		UpdatedAt:      time.Now().Unix(),
+	}
+	if err := s.store.put(storeKey(prefixTxRecord, hash.Bytes()), record); err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if err := s.store.put(storeKey(prefixIdempotencyKey, []byte(key)), hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// submitTrackedTx submits the tx if it has not been submitted,
+// otherwise broadcasts the submitted tx again if it is not broadcast yet,
+// and returns the hash of the submitted tx after waiting as the wait level.
+func (s *Server) submitTrackedTx(ctx context.Context, tx *types.Transaction, from common.Address, wait uint64, key string) (common.Hash, error) {
+	record, err := s.prepareSubmission(tx, from, key)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if record == nil {
+		hash, err := s.submitTx(ctx, tx, from, wait)
+		s.metrics.observeSubmission(wait, err)
+		if err != nil {
+			// the tx broadcast but not mined in time is kept as broadcast and confirmed later
+			if _, ok := err.(*waitMinedError); !ok {
+				s.trackFailed(tx, err)
+			}
+			return common.Hash{}, err
+		}
+		return hash, nil
+	}
+
+	if submittedDone(record, wait) {
+		return record.Hash, nil
+	}
+
+	client, err := ethclient.Dial(s.rpcURL)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if err := s.rebroadcastSubmitted(ctx, client, tx, from, record); err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.waitSubmitted(ctx, client, tx, from, record, wait); err != nil {
+		return common.Hash{}, err
+	}
+
+	return record.Hash, nil
+}
+
+// submittedDone returns true if the submitted tx has been broadcast and reached the wait level
+func submittedDone(record *TxRecord, wait uint64) bool {
+	broadcast := statusRank[record.Status] >= statusRank[StatusBroadcast]
+	return broadcast && (wait != params.LevelWaitConfirmed || record.Status == StatusConfirmed)
+}
+
+// rebroadcastSubmitted broadcasts the submitted tx again if it is not broadcast yet.
+// The tx not broadcast may be lost, e.g. queued in memory when the server restarted,
+// the tx already known by the node is treated as broadcast.
+func (s *Server) rebroadcastSubmitted(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address, record *TxRecord) error {
+	if statusRank[record.Status] >= statusRank[StatusBroadcast] {
+		return nil
+	}
+
+	if err := s.broadcastTx(ctx, client, tx, from); err != nil {
+		s.trackFailed(tx, err)
+		return err
+	}
+
+	return nil
+}
+
+// waitSubmitted waits the submitted tx as the wait level after rebroadcastSubmitted,
+// the record is the state before rebroadcast
+func (s *Server) waitSubmitted(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address, record *TxRecord, wait uint64) error {
+	if submittedDone(record, wait) {
+		return nil
+	}
+
+	// broadcast before, it is confirmed by the one broadcast it
+	if statusRank[record.Status] >= statusRank[StatusBroadcast] {
+		_, err := bind.WaitMined(ctx, client, tx)
+		return err
+	}
+
+	// broadcast by rebroadcastSubmitted
+	if wait != params.LevelWaitConfirmed {
+		s.txChan <- tx2Confirm{tx: tx, from: from}
+		return nil
+	}
+	return s.waitConfirmed(ctx, client, tx, from)
+}
+
+// trackTx updates the status of the tracked tx, the status only goes forward
+func (s *Server) trackTx(hash common.Hash, status string) {
+	s.submissionLock.Lock()
+	defer s.submissionLock.Unlock()
+
+	record := new(TxRecord)
+	found, err := s.store.get(storeKey(prefixTxRecord, hash.Bytes()), record)
+	if err != nil {
+		log.Errorf("%s: get tx record error: %v\n", hash.String(), err)
+		return
+	}
+	if !found || record.Status == StatusFailed || statusRank[status] <= statusRank[record.Status] {
+		return
+	}
+
+	record.Status = status
+	record.UpdatedAt = time.Now().Unix()
+	if err := s.store.put(storeKey(prefixTxRecord, hash.Bytes()), record); err != nil {
+		log.Errorf("%s: put tx record error: %v\n", hash.String(), err)
+	}
+}
+
+// trackFailed marks the tracked tx as failed, so the tx can be submitted again
+func (s *Server) trackFailed(tx *types.Transaction, reason error) {
+	hash := tx.Hash()
+
+	s.submissionLock.Lock()
+	defer s.submissionLock.Unlock()
+
+	record := new(TxRecord)
+	found, err := s.store.get(storeKey(prefixTxRecord, hash.Bytes()), record)
+	if err != nil {
+		log.Errorf("%s: get tx record error: %v\n", hash.String(), err)
+		return
+	}
+	if !found || record.Status == StatusConfirmed {
+		return
+	}
+
+	s.stages.forget(hash)
+
+	record.Status = StatusFailed
+	record.Error = reason.Error()
+	record.UpdatedAt = time.Now().Unix()
+	if err := s.store.put(storeKey(prefixTxRecord, hash.Bytes()), record); err != nil {
+		log.Errorf("%s: put tx record error: %v\n", hash.String(), err)
+	}
+
+	s.publishStatus(newTransferTx(tx, record.From), notification.StageFailed, record.Error)
+}
+
+// isKnownTxError returns true if the node already has the tx in the pool
+func isKnownTxError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "known transaction")
+}
+
+// GetSubmissionArgs is the hash or the idempotency key of the submitted tx
+type GetSubmissionArgs struct {
+	Hash           *common.Hash `json:"hash"`
+	IdempotencyKey string       `json:"idempotencyKey"`
+}
+
+// GetSubmission returns the tracked state of the submitted tx
+func (s *Server) GetSubmission(ctx context.Context, args GetSubmissionArgs) (*TxRecord, error) {
+	var hash common.Hash
+	if args.Hash != nil {
+		hash = *args.Hash
+	} else if args.IdempotencyKey != "" {
+		found, err := s.store.get(storeKey(prefixIdempotencyKey, []byte(args.IdempotencyKey)), &hash)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.New("submission not found")
+		}
+	} else {
+		return nil, errors.New("hash or idempotencyKey required")
+	}
+
+	record := new(TxRecord)
+	found, err := s.store.get(storeKey(prefixTxRecord, hash.Bytes()), record)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("submission not found")
+	}
+	record.FromNEW, record.ToNEW = s.addressNEW(&record.From), s.addressNEW(record.To)
+
+	return record, nil
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/newtonproject/newchain-api-express/params"
+	"github.com/newtonproject/newchain-api-express/rpc"
+)
+
+func newTestStoreServer(t *testing.T) (*Server, func()) {
+	stages, err := newStageTimer(stageTimerSize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "newchain-api-express")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := openStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Server{store: st, stages: stages}, func() {
+		st.close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPrepareSubmission(t *testing.T) {
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+
+	from := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	tx := types.NewTransaction(1, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+	other := types.NewTransaction(2, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+
+	record, err := s.prepareSubmission(tx, from, "key-1")
+	if err != nil || record != nil {
+		t.Fatalf("first submission: want new, got %v %v", record, err)
+	}
+
+	record, err = s.prepareSubmission(tx, from, "key-1")
+	if err != nil || record == nil || record.Hash != tx.Hash() || record.Status != StatusReceived {
+		t.Fatalf("retry: want received record, got %v %v", record, err)
+	}
+
+	if _, err := s.prepareSubmission(other, from, "key-1"); err == nil {
+		t.Fatal("reused key with other tx: want error")
+	}
+
+	s.trackTx(tx.Hash(), StatusConfirmed)
+	s.trackTx(tx.Hash(), StatusBroadcast)
+	record, _ = s.prepareSubmission(tx, from, "")
+	if record == nil || record.Status != StatusConfirmed {
+		t.Fatalf("status should only go forward, got %v", record)
+	}
+
+	s.trackFailed(other, errors.New("failed"))
+	if _, err := s.prepareSubmission(other, from, ""); err != nil {
+		t.Fatal(err)
+	}
+	s.trackFailed(other, errors.New("failed"))
+	record, err = s.prepareSubmission(other, from, "")
+	if err != nil || record != nil {
+		t.Fatalf("failed tx should be submitted again, got %v %v", record, err)
+	}
+}
+
+func TestSubmitTrackedTxRebroadcast(t *testing.T) {
+	upstream := new(EthService)
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	s.rpcURL = upstreamHTTP.URL
+	s.txChan = make(chan interface{}, 10)
+
+	from := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	tx := types.NewTransaction(1, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+	ctx := context.Background()
+
+	// received but lost before broadcast, e.g. the server restarted
+	if record, err := s.prepareSubmission(tx, from, ""); err != nil || record != nil {
+		t.Fatalf("first submission: want new, got %v %v", record, err)
+	}
+	if _, err := s.submitTrackedTx(ctx, tx, from, params.LevelWaitBroadcast, ""); err != nil {
+		t.Fatal(err)
+	}
+	if upstream.sendCalls != 1 {
+		t.Fatalf("received tx: want broadcast again, got %d sends", upstream.sendCalls)
+	}
+
+	// the tx already known by the node is broadcast
+	if _, err := s.submitTrackedTx(ctx, tx, from, params.LevelNoWait, ""); err != nil {
+		t.Fatalf("known tx: want broadcast, got %v", err)
+	}
+	if upstream.sendCalls != 2 {
+		t.Fatalf("received tx: want broadcast again, got %d sends", upstream.sendCalls)
+	}
+
+	s.trackTx(tx.Hash(), StatusBroadcast)
+	if _, err := s.submitTrackedTx(ctx, tx, from, params.LevelWaitBroadcast, ""); err != nil {
+		t.Fatal(err)
+	}
+	if upstream.sendCalls != 2 {
+		t.Fatalf("broadcast tx: want no send, got %d sends", upstream.sendCalls)
+	}
+}
+
+func TestSubmitTrackedTxWaitTimeout(t *testing.T) {
+	upstream := new(EthService)
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	s.rpcURL = upstreamHTTP.URL
+	s.txChan = make(chan interface{}, 10)
+
+	from := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	tx := types.NewTransaction(1, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+
+	// the node has no receipt, so the wait times out after broadcast
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := s.submitTrackedTx(ctx, tx, from, params.LevelWaitConfirmed, "key")
+	if _, ok := err.(*waitMinedError); !ok {
+		t.Fatalf("want wait mined error, got %v", err)
+	}
+
+	var queued bool
+	for len(s.txChan) > 0 {
+		switch msg := (<-s.txChan).(type) {
+		case txNotifyBroadcast:
+			s.trackTx(msg.tx.Hash, StatusBroadcast)
+		case tx2Confirm:
+			queued = msg.tx.Hash() == tx.Hash()
+		}
+	}
+	if !queued {
+		t.Fatal("the timed out tx should be queued to be confirmed")
+	}
+	record, err := s.GetSubmission(context.Background(), GetSubmissionArgs{IdempotencyKey: "key"})
+	if err != nil || record.Status != StatusBroadcast {
+		t.Fatalf("want broadcast record, got %+v %v", record, err)
+	}
+
+	// the retry with the same key is not broadcast again
+	if hash, err := s.submitTrackedTx(context.Background(), tx, from, params.LevelWaitBroadcast, "key"); err != nil || hash != tx.Hash() {
+		t.Fatalf("retry: want %s, got %s %v", tx.Hash().String(), hash.String(), err)
+	}
+	if upstream.sendCalls != 1 {
+		t.Fatalf("retry: want no send, got %d sends", upstream.sendCalls)
+	}
+}
+
+func TestSubmitBatchResume(t *testing.T) {
+	upstream := new(EthService)
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	s.rpcURL = upstreamHTTP.URL
+	s.txChan = make(chan interface{}, 10)
+
+	from := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	received := types.NewTransaction(1, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+	broadcast := types.NewTransaction(2, from, big.NewInt(1), 21000, big.NewInt(100), nil)
+	for _, tx := range []*types.Transaction{received, broadcast} {
+		if record, err := s.prepareSubmission(tx, from, ""); err != nil || record != nil {
+			t.Fatalf("first submission: want new, got %v %v", record, err)
+		}
+	}
+	s.trackTx(broadcast.Hash(), StatusBroadcast)
+
+	// the received tx is broadcast again, the broadcast tx is returned at once
+	results := make([]*SendTxResult, 2)
+	var txs []*batchTx
+	for i, tx := range []*types.Transaction{received, broadcast} {
+		if btx := s.prepareBatchTx(i, tx, from, params.LevelNoWait, "", results); btx != nil {
+			txs = append(txs, btx)
+		}
+	}
+	if len(txs) != 1 || txs[0].record == nil || txs[0].tx.Hash() != received.Hash() {
+		t.Fatalf("want the received tx resumed, got %d txs", len(txs))
+	}
+	s.submitBatch(context.Background(), txs, results)
+
+	for i, tx := range []*types.Transaction{received, broadcast} {
+		if results[i] == nil || results[i].Hash == nil || *results[i].Hash != tx.Hash() {
+			t.Fatalf("result %d: want %s, got %+v", i, tx.Hash().String(), results[i])
+		}
+	}
+	if upstream.sendCalls != 1 {
+		t.Fatalf("want the received tx broadcast again, got %d sends", upstream.sendCalls)
+	}
+
+	if _, err := s.SendRawTransactions(context.Background(), make([]SendRawTxArgs, maxBatchTransactions+1)); err == nil {
+		t.Fatal("too many transactions: want error")
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/newtonproject/newchain-api-express/rpc"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+const (
+	// receiptCacheSize is the max number of the finalized receipts in cache
+	receiptCacheSize = 4096
+	// finalizedConfirmations is the confirmations of the finalized receipt which will not be reorganized
+	finalizedConfirmations = 12
+)
+
+// status of the receipt
+const (
+	ReceiptStatusSuccess = "success"
+	ReceiptStatusFailed  = "failed"
+)
+
+var errTxNotFound = errors.New("transaction not found")
+
+// HashArgs is the hash of the transaction
+type HashArgs struct {
+	Hash common.Hash `json:"hash"`
+}
+
+// Transaction is the client-friendly transaction
+type Transaction struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	FromNEW          string          `json:"fromNEW,omitempty"`
+	ToNEW            string          `json:"toNEW,omitempty"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	Value            *hexutil.Big    `json:"value"`
+	ValueNEW         string          `json:"valueNEW"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	Input            hexutil.Bytes   `json:"input"`
+	Pending          bool            `json:"pending"`
+	BlockNumber      *hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        *common.Hash    `json:"blockHash"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+	Confirmations    hexutil.Uint64  `json:"confirmations"`
+}
+
+// Receipt is the client-friendly receipt
+type Receipt struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	ContractAddress  *common.Address `json:"contractAddress"`
+	FromNEW          string          `json:"fromNEW,omitempty"`
+	ToNEW            string          `json:"toNEW,omitempty"`
+	ContractNEW      string          `json:"contractAddressNEW,omitempty"`
+	Status           string          `json:"status"`
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	BlockHash        common.Hash     `json:"blockHash"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+	Value            *hexutil.Big    `json:"value"`
+	ValueNEW         string          `json:"valueNEW"`
+	GasUsed          hexutil.Uint64  `json:"gasUsed"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Fee              *hexutil.Big    `json:"fee"`
+	FeeNEW           string          `json:"feeNEW"`
+	Logs             hexutil.Uint    `json:"logs"`
+	Confirmations    hexutil.Uint64  `json:"confirmations"`
+}
+
+// rpcTransaction is the result of eth_getTransactionByHash
+type rpcTransaction struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	Value            *hexutil.Big    `json:"value"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	Input            hexutil.Bytes   `json:"input"`
+	BlockNumber      *hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        *common.Hash    `json:"blockHash"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// rpcReceipt is the result of eth_getTransactionReceipt
+type rpcReceipt struct {
+	TransactionHash  common.Hash     `json:"transactionHash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	ContractAddress  *common.Address `json:"contractAddress"`
+	Status           *hexutil.Uint64 `json:"status"`
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	BlockHash        common.Hash     `json:"blockHash"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+	GasUsed          hexutil.Uint64  `json:"gasUsed"`
+	Logs             []interface{}   `json:"logs"`
+}
+
+// GetTransaction returns the transaction with the confirmations
+func (s *Server) GetTransaction(ctx context.Context, args HashArgs) (*Transaction, error) {
+	client, err := rpc.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	tx, err := getRPCTransaction(ctx, client, args.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Transaction{
+		Hash:             tx.Hash,
+		From:             tx.From,
+		To:               tx.To,
+		FromNEW:          s.addressNEW(&tx.From),
+		ToNEW:            s.addressNEW(tx.To),
+		Nonce:            tx.Nonce,
+		Value:            tx.Value,
+		ValueNEW:         utils.GetISAACAmountTextByUnit(tx.Value.ToInt(), "NEW"),
+		GasPrice:         tx.GasPrice,
+		Gas:              tx.Gas,
+		Input:            tx.Input,
+		Pending:          tx.BlockNumber == nil,
+		BlockNumber:      tx.BlockNumber,
+		BlockHash:        tx.BlockHash,
+		TransactionIndex: tx.TransactionIndex,
+	}
+	if tx.BlockNumber != nil {
+		head, err := getBlockNumber(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		result.Confirmations = confirmations(head, uint64(*tx.BlockNumber))
+	}
+
+	return result, nil
+}
+
+// GetReceipt returns the receipt with the status, fee and confirmations.
+// The finalized receipts are cached.
+func (s *Server) GetReceipt(ctx context.Context, args HashArgs) (*Receipt, error) {
+	client, err := rpc.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	head, err := getBlockNumber(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := s.receiptCache.Get(args.Hash); ok {
+		receipt := *cached.(*Receipt)
+		receipt.Confirmations = confirmations(head, uint64(receipt.BlockNumber))
+		return &receipt, nil
+	}
+
+	r, err := getRPCReceipt(ctx, client, args.Hash)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := getRPCTransaction(ctx, client, args.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := new(big.Int).Mul(tx.GasPrice.ToInt(), new(big.Int).SetUint64(uint64(r.GasUsed)))
+	receipt := &Receipt{
+		Hash:             r.TransactionHash,
+		From:             r.From,
+		To:               r.To,
+		ContractAddress:  r.ContractAddress,
+		FromNEW:          s.addressNEW(&r.From),
+		ToNEW:            s.addressNEW(r.To),
+		ContractNEW:      s.addressNEW(r.ContractAddress),
+		Status:           ReceiptStatusSuccess,
+		BlockNumber:      r.BlockNumber,
+		BlockHash:        r.BlockHash,
+		TransactionIndex: r.TransactionIndex,
+		Value:            tx.Value,
+		ValueNEW:         utils.GetISAACAmountTextByUnit(tx.Value.ToInt(), "NEW"),
+		GasUsed:          r.GasUsed,
+		GasPrice:         tx.GasPrice,
+		Fee:              (*hexutil.Big)(fee),
+		FeeNEW:           utils.GetISAACAmountTextByUnit(fee, "NEW"),
+		Logs:             hexutil.Uint(len(r.Logs)),
+		Confirmations:    confirmations(head, uint64(r.BlockNumber)),
+	}
+	if r.Status != nil && *r.Status == 0 {
+		receipt.Status = ReceiptStatusFailed
+	}
+
+	if receipt.Confirmations >= finalizedConfirmations {
+		cached := *receipt
+		s.receiptCache.Add(args.Hash, &cached)
+	}
+
+	return receipt, nil
+}
+
+func getRPCTransaction(ctx context.Context, client *rpc.Client, hash common.Hash) (*rpcTransaction, error) {
+	var tx *rpcTransaction
+	if err := client.CallArrayContext(ctx, &tx, "eth_getTransactionByHash", hash); err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, errTxNotFound
+	}
+
+	return tx, nil
+}
+
+func getRPCReceipt(ctx context.Context, client *rpc.Client, hash common.Hash) (*rpcReceipt, error) {
+	var r *rpcReceipt
+	if err := client.CallArrayContext(ctx, &r, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, errTxNotFound
+	}
+
+	return r, nil
+}
+
+// notificationReceipt returns the receipt fields of the notification
+func (r *rpcReceipt) notificationReceipt() *notification.Receipt {
+	status := hexutil.Uint64(types.ReceiptStatusSuccessful)
+	if r.Status != nil {
+		status = *r.Status
+	}
+
+	return &notification.Receipt{
+		Status:           status,
+		BlockNumber:      r.BlockNumber,
+		BlockHash:        r.BlockHash,
+		TransactionIndex: r.TransactionIndex,
+		GasUsed:          r.GasUsed,
+		ContractAddress:  r.ContractAddress,
+	}
+}
+
+func getBlockNumber(ctx context.Context, client *rpc.Client) (uint64, error) {
+	var number hexutil.Uint64
+	if err := client.CallArrayContext(ctx, &number, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+
+	return uint64(number), nil
+}
+
+// confirmations is the number of blocks from the block to the head, including the block
+func confirmations(head, number uint64) hexutil.Uint64 {
+	if head < number {
+		return 0
+	}
+	return hexutil.Uint64(head - number + 1)
+}
@@ -5,10 +5,10 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/newtonproject/newchain-api-express/rpc"
 )
 
 type tx2Broadcast struct {
@@ -33,6 +33,12 @@ type txNotifyConfirmed struct {
 	tx *TransferTx
 }
 
+// txNotifyWatched is the transfer of the watched addresses found by the scanner, it is not tracked
+type txNotifyWatched struct {
+	tx        *TransferTx
+	confirmed int64
+}
+
 func (s *Server) handleTxs() {
 	for {
 		select {
@@ -46,20 +52,21 @@ func (s *Server) handleTxs() {
 				tx := msg.tx
 				from := msg.from
 				s.txs2ConfirmLock.Lock()
-				s.txs2Confirm = append(s.txs2Confirm, &TransferTx{
-					From:  from,
-					To:    tx.To(),
-					Value: tx.Value(),
-					Hash:  tx.Hash(),
-					Data:  tx.Data(),
-				})
+				s.txs2Confirm = append(s.txs2Confirm, newTransferTx(tx, from))
 				s.txs2ConfirmLock.Unlock()
 			case txNotifyReceived:
+				s.stages.markReceived(msg.tx.Hash)
 				s.sendNotify(msg.tx, -1)
 			case txNotifyBroadcast:
+				s.stages.markBroadcast(msg.tx.Hash)
+				s.trackTx(msg.tx.Hash, StatusBroadcast)
 				s.sendNotify(msg.tx, 0)
 			case txNotifyConfirmed:
+				s.stages.markMined(msg.tx.Hash)
+				s.trackTx(msg.tx.Hash, StatusConfirmed)
 				s.sendNotify(msg.tx, 1)
+			case txNotifyWatched:
+				s.sendNotify(msg.tx, msg.confirmed)
 			default:
 				log.Warningf("Unknown message type sent: %T", msg)
 			}
@@ -74,36 +81,48 @@ func (s *Server) handleBroadcastTx(tx *types.Transaction, from common.Address) {
 		return
 	}
 
+	start := time.Now()
 	err = client.SendTransaction(context.Background(), tx)
-	if err != nil {
+	s.metrics.observeUpstream("sendTransaction", start)
+	if err != nil && !isKnownTxError(err) {
+		s.metrics.observeUpstreamError("sendTransaction")
 		log.Errorf("%s: SendTransaction error: %v\n", tx.Hash().String(), err)
+		s.trackFailed(tx, err)
 		return
 	}
 
 	// send notify
 	// notify Broadcast
-	s.txChan <- txNotifyBroadcast{tx: &TransferTx{
-		From:  from,
-		To:    tx.To(),
-		Value: tx.Value(),
-		Hash:  tx.Hash(),
-		Data:  tx.Data(),
-	}}
+	s.txChan <- txNotifyBroadcast{tx: newTransferTx(tx, from)}
 
 	// ok, wait to be mined
 	s.txs2ConfirmLock.Lock()
-	s.txs2Confirm = append(s.txs2Confirm, &TransferTx{
-		From:  from,
-		To:    tx.To(),
-		Value: tx.Value(),
-		Hash:  tx.Hash(),
-		Data:  tx.Data(),
-	})
+	s.txs2Confirm = append(s.txs2Confirm, newTransferTx(tx, from))
 	s.txs2ConfirmLock.Unlock()
 
 	return
 }
 
+// fillReceipt sets the block number and the receipt of the confirmed tx, the error is only logged
+func (s *Server) fillReceipt(ctx context.Context, tx *TransferTx) {
+	client, err := rpc.DialContext(ctx, s.rpcURL)
+	if err != nil {
+		log.Errorf("%s: fillReceipt Dial error: %v\n", tx.Hash.String(), err)
+		return
+	}
+	defer client.Close()
+
+	start := time.Now()
+	receipt, err := getRPCReceipt(ctx, client, tx.Hash)
+	s.metrics.observeUpstream("transactionReceipt", start)
+	if err != nil {
+		s.metrics.observeUpstreamError("transactionReceipt")
+		log.Errorf("%s: fillReceipt TransactionReceipt error: %v\n", tx.Hash.String(), err)
+		return
+	}
+	tx.setReceipt(receipt)
+}
+
 func (s *Server) handleTxs2Confirm() {
 	// get block inter
 	var blockPeriod int64
@@ -156,28 +175,34 @@ func (s *Server) handleTxs2Confirm() {
 			return
 		}
 
-		client, err := ethclient.Dial(s.rpcURL)
+		ctx := context.Background()
+		client, err := rpc.DialContext(ctx, s.rpcURL)
 		if err != nil {
 			log.Errorf("handleTxs2Confirm Dial error: %v\n", err)
 			return
 		}
+		defer client.Close()
 
 		for _, tx := range txs {
-			receipt, err := client.TransactionReceipt(context.Background(), tx.Hash)
-			if receipt == nil {
+			start := time.Now()
+			receipt, err := getRPCReceipt(ctx, client, tx.Hash)
+			s.metrics.observeUpstream("transactionReceipt", start)
+			if err != nil {
 				// add tx back to txs
 				s.txs2ConfirmLock.Lock()
 				s.txs2Confirm = append(s.txs2Confirm, tx)
 				s.txs2ConfirmLock.Unlock()
 
-				if err != nil && err != ethereum.NotFound {
-					log.Errorln("handleTxs2Confirm TransactionReceipt error: %v\n", err)
+				if err != errTxNotFound {
+					s.metrics.observeUpstreamError("transactionReceipt")
+					log.Errorf("handleTxs2Confirm TransactionReceipt error: %v\n", err)
 				}
 
 				continue
 			}
 
 			// ok, found confirmed tx, notify
+			tx.setReceipt(receipt)
 
 			// notify confirmed
 			s.txChan <- txNotifyConfirmed{tx: tx}
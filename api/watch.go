@@ -0,0 +1,341 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/newtonproject/newchain-api-express/hdwallet"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+// WatchAddressArgs is the address in hex or NEW format to watch or unwatch
+type WatchAddressArgs struct {
+	Address utils.Address `json:"address"`
+}
+
+// WatchAddress adds the address to the watched addresses,
+// the transfers to or from it are notified even not submitted through the server.
+// It returns false if the address is already watched.
+func (s *Server) WatchAddress(ctx context.Context, args WatchAddressArgs) (bool, error) {
+	if err := s.checkAddresses(&args.Address); err != nil {
+		return false, err
+	}
+
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	return s.watch(args.Address.Address)
+}
+
+// maxWatchXPubAddresses is the max number of addresses in one newton_watchXPub call
+const maxWatchXPubAddresses = 1000
+
+// WatchXPubArgs is the extended public key of the account and the range of the address indexes
+type WatchXPubArgs struct {
+	XPub  string `json:"xpub"`
+	Start uint64 `json:"start"`
+	Count uint64 `json:"count"`
+}
+
+// WatchXPub watches the addresses <xpub>/0/<index> for the indexes in [start, start+count),
+// the xpub is exported by the client so the server generates the addresses without the keys.
+// It returns the derived addresses.
+func (s *Server) WatchXPub(ctx context.Context, args WatchXPubArgs) ([]common.Address, error) {
+	if args.Count == 0 || args.Count > maxWatchXPubAddresses {
+		return nil, fmt.Errorf("count should be in [1, %d]", maxWatchXPubAddresses)
+	}
+	if args.Start+args.Count > hdwallet.HardenedKeyStart {
+		return nil, errors.New("index out of range")
+	}
+
+	addresses := make([]common.Address, 0, args.Count)
+	for index := args.Start; index < args.Start+args.Count; index++ {
+		address, err := hdwallet.DeriveAddress(args.XPub, fmt.Sprintf("0/%d", index))
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	for _, address := range addresses {
+		if _, err := s.watch(address); err != nil {
+			return nil, err
+		}
+	}
+
+	return addresses, nil
+}
+
+// watch adds the address to the watched addresses, the watchedLock must be held
+func (s *Server) watch(address common.Address) (bool, error) {
+	if _, ok := s.watched[address]; ok {
+		return false, nil
+	}
+	if err := s.store.put(storeKey(prefixWatched, address.Bytes()), true); err != nil {
+		return false, err
+	}
+	s.watched[address] = struct{}{}
+
+	return true, nil
+}
+
+// UnwatchAddress removes the address from the watched addresses.
+// It returns false if the address is not watched.
+func (s *Server) UnwatchAddress(ctx context.Context, args WatchAddressArgs) (bool, error) {
+	if err := s.checkAddresses(&args.Address); err != nil {
+		return false, err
+	}
+	address := args.Address.Address
+
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	if _, ok := s.watched[address]; !ok {
+		return false, nil
+	}
+	if err := s.store.delete(storeKey(prefixWatched, address.Bytes())); err != nil {
+		return false, err
+	}
+	delete(s.watched, address)
+
+	return true, nil
+}
+
+// GetWatchedAddresses returns all the watched addresses
+func (s *Server) GetWatchedAddresses(ctx context.Context) ([]common.Address, error) {
+	s.watchedLock.RLock()
+	defer s.watchedLock.RUnlock()
+
+	addresses := make([]common.Address, 0, len(s.watched))
+	for address := range s.watched {
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// loadWatched loads the watched addresses from the store
+func (s *Server) loadWatched() error {
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	s.watched = make(map[common.Address]struct{})
+	return s.store.iteratePrefix(prefixWatched, func(key, value []byte) bool {
+		s.watched[common.BytesToAddress(key[len(prefixWatched):])] = struct{}{}
+		return true
+	})
+}
+
+func (s *Server) isWatched(address *common.Address) bool {
+	if address == nil {
+		return false
+	}
+
+	s.watchedLock.RLock()
+	defer s.watchedLock.RUnlock()
+
+	_, ok := s.watched[*address]
+	return ok
+}
+
+func (s *Server) watchedCount() int {
+	s.watchedLock.RLock()
+	defer s.watchedLock.RUnlock()
+
+	return len(s.watched)
+}
+
+func (s *Server) handleWatched() {
+	for {
+		if err := s.scanWatched(); err != nil {
+			s.metrics.observeUpstreamError("scanner")
+			log.Errorf("Scan watched addresses error: %v\n", err)
+		}
+
+		time.Sleep(time.Second * 3)
+	}
+}
+
+// scanWatched scans the new blocks for the transfers of the watched addresses.
+// The transfers are notified as received once mined, and as confirmed after WatchConfirmations blocks,
+// the confirmed are scanned again from the canonical chain, so the transfers reorganized out are never confirmed.
+// It starts from the latest block at the first run, and continues from the last scanned blocks after restart.
+func (s *Server) scanWatched() error {
+	client, err := ethclient.Dial(s.rpcURL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	latest, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	head := latest.Number.Uint64()
+
+	received, err := s.scannerNext(keyScannerReceived, head)
+	if err != nil {
+		return err
+	}
+	// the confirmed scanner starts from the first block of the received scanner
+	start := received
+	for ; received <= head; received++ {
+		if err := s.scanBlock(ctx, client, received, -1, keyScannerReceived); err != nil {
+			return err
+		}
+	}
+
+	confirmations := uint64(finalizedConfirmations)
+	if s.notify != nil && s.notify.WatchConfirmations > 0 {
+		confirmations = s.notify.WatchConfirmations
+	}
+	confirmed, err := s.scannerNext(keyScannerNext, start)
+	if err != nil {
+		return err
+	}
+	for ; confirmed+confirmations <= head; confirmed++ {
+		if err := s.scanBlock(ctx, client, confirmed, 1, keyScannerNext); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scannerNext returns the next block of the scanner saved in the key,
+// or starts the scanner from start if not scanned yet or no address is watched.
+// The start is saved at once, so the scanner moves forward even if no block is scanned in this run.
+func (s *Server) scannerNext(key []byte, start uint64) (uint64, error) {
+	var next uint64
+	found, err := s.store.get(key, &next)
+	if err != nil {
+		return 0, err
+	}
+	if !found || s.watchedCount() == 0 {
+		return start, s.store.put(key, start)
+	}
+
+	return next, nil
+}
+
+// scanBlock notifies the transfers of the watched addresses in the block at the confirmed level,
+// then saves the next block to the key. The transfers are notified after all of them are fetched,
+// so the block is never notified partially and scanned again after an error.
+func (s *Server) scanBlock(ctx context.Context, client *ethclient.Client, number uint64, confirmed int64, key []byte) error {
+	transfers, err := s.watchedTransfers(ctx, client, number)
+	if err != nil {
+		return fmt.Errorf("scan block %d: %v", number, err)
+	}
+	for _, tx := range transfers {
+		s.txChan <- txNotifyWatched{tx: tx, confirmed: confirmed}
+	}
+
+	return s.store.put(key, number+1)
+}
+
+// watchedTransfers returns the NEW transfers and the token Transfer events of the watched addresses in the block,
+// the txs submitted through the server are skipped as they are notified already
+func (s *Server) watchedTransfers(ctx context.Context, client *ethclient.Client, number uint64) ([]*TransferTx, error) {
+	if s.watchedCount() == 0 {
+		return nil, nil
+	}
+
+	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []*TransferTx
+	signer := types.NewEIP155Signer(new(big.Int).SetUint64(s.networkID))
+	for i, tx := range block.Transactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		if !s.isWatched(&from) && !s.isWatched(tx.To()) {
+			continue
+		}
+
+		found, err := s.store.get(storeKey(prefixTxRecord, tx.Hash().Bytes()), new(TxRecord))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			continue
+		}
+
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			continue
+		}
+
+		transferTx := newTransferTx(tx, from)
+		transferTx.BlockNumber = block.Number()
+		transferTx.receipt = &notification.Receipt{
+			Status:           hexutil.Uint64(receipt.Status),
+			BlockNumber:      hexutil.Uint64(number),
+			BlockHash:        block.Hash(),
+			TransactionIndex: hexutil.Uint64(i),
+			GasUsed:          hexutil.Uint64(receipt.GasUsed),
+		}
+		if tx.To() == nil {
+			transferTx.receipt.ContractAddress = &receipt.ContractAddress
+		}
+		transfers = append(transfers, transferTx)
+	}
+
+	blockHash := block.Hash()
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &blockHash,
+		Topics:    [][]common.Hash{{transferEventSig}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(transfers, s.watchedTokenTransfers(logs)...), nil
+}
+
+// watchedTokenTransfers returns the token Transfer events from or to the watched addresses,
+// the logs are of the successful txs only
+func (s *Server) watchedTokenTransfers(logs []types.Log) []*TransferTx {
+	var transfers []*TransferTx
+	for _, l := range logs {
+		htx := transferLogToHistory(l, 0)
+		if l.Removed || htx == nil || (!s.isWatched(&htx.From) && !s.isWatched(htx.To)) {
+			continue
+		}
+
+		transfers = append(transfers, &TransferTx{
+			From:        htx.From,
+			To:          htx.To,
+			Value:       htx.Value.ToInt(),
+			Hash:        l.TxHash,
+			BlockNumber: new(big.Int).SetUint64(l.BlockNumber),
+			Token:       htx.Token,
+			receipt: &notification.Receipt{
+				Status:           hexutil.Uint64(types.ReceiptStatusSuccessful),
+				BlockNumber:      hexutil.Uint64(l.BlockNumber),
+				BlockHash:        l.BlockHash,
+				TransactionIndex: hexutil.Uint64(l.TxIndex),
+			},
+		})
+	}
+
+	return transfers
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/newtonproject/newchain-api-express/hdwallet"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/newtonproject/newchain-api-express/rpc"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+func TestWatchAddress(t *testing.T) {
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	s.networkID = 1007
+
+	if err := s.loadWatched(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	addr := common.HexToAddress("0xffd639a62be604374ff04af4112a555890bd822a")
+	other := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	for _, a := range []common.Address{addr, other} {
+		if ok, err := s.WatchAddress(ctx, WatchAddressArgs{Address: utils.Address{Address: a}}); err != nil || !ok {
+			t.Fatalf("watch %s: want true, got %v %v", a.String(), ok, err)
+		}
+	}
+	if ok, err := s.WatchAddress(ctx, WatchAddressArgs{Address: utils.Address{Address: addr}}); err != nil || ok {
+		t.Fatalf("watch again: want false, got %v %v", ok, err)
+	}
+
+	// the NEW format address of another chain is rejected
+	var args WatchAddressArgs
+	if err := json.Unmarshal([]byte(`{"address":"`+utils.AddressToNew(1012, other)+`"}`), &args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UnwatchAddress(ctx, args); err == nil {
+		t.Fatal("NEW address of another chain accepted")
+	}
+	if ok, err := s.UnwatchAddress(ctx, WatchAddressArgs{Address: utils.Address{Address: other}}); err != nil || !ok {
+		t.Fatalf("unwatch: want true, got %v %v", ok, err)
+	}
+
+	// reload from the store
+	if err := s.loadWatched(); err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := s.GetWatchedAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 1 || addresses[0] != addr {
+		t.Fatalf("watched: want [%s], got %v", addr.String(), addresses)
+	}
+	if !s.isWatched(&addr) || s.isWatched(&other) || s.isWatched(nil) {
+		t.Fatal("isWatched mismatch")
+	}
+
+	// the addresses derived from the xpub are the addresses of the private keys
+	w, err := hdwallet.NewFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpub, err := w.XPub(hdwallet.DefaultAccountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived, err := s.WatchXPub(ctx, WatchXPubArgs{XPub: xpub, Start: 2, Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, address := range derived {
+		want, err := w.Address(fmt.Sprintf("%s/%d", hdwallet.DefaultChainPath, 2+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != want || !s.isWatched(&address) {
+			t.Fatalf("derived %d: want watched %s, got %s", i, want.String(), address.String())
+		}
+	}
+	if _, err := s.WatchXPub(ctx, WatchXPubArgs{XPub: xpub}); err == nil {
+		t.Fatal("zero count accepted")
+	}
+}
+
+func TestWatchedTokenTransfers(t *testing.T) {
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	if err := s.loadWatched(); err != nil {
+		t.Fatal(err)
+	}
+
+	watched := common.HexToAddress("0xffd639a62be604374ff04af4112a555890bd822a")
+	other := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	token := common.HexToAddress("0xd639a62be604374ff04af4112a555890bd822a03")
+	if _, err := s.WatchAddress(context.Background(), WatchAddressArgs{Address: utils.Address{Address: watched}}); err != nil {
+		t.Fatal(err)
+	}
+
+	transferLog := func(from, to common.Address, removed bool) types.Log {
+		return types.Log{
+			Address:     token,
+			Topics:      []common.Hash{transferEventSig, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:        common.LeftPadBytes(big.NewInt(100).Bytes(), 32),
+			BlockNumber: 10,
+			TxHash:      common.HexToHash("0x01"),
+			TxIndex:     2,
+			Removed:     removed,
+		}
+	}
+	logs := []types.Log{
+		transferLog(other, watched, false),
+		transferLog(watched, other, false),
+		transferLog(other, other, false),
+		transferLog(other, watched, true),
+	}
+
+	transfers := s.watchedTokenTransfers(logs)
+	if len(transfers) != 2 {
+		t.Fatalf("token transfers: want 2, got %d", len(transfers))
+	}
+	in := transfers[0]
+	if in.Token == nil || *in.Token != token || *in.To != watched || in.From != other || in.Value.Int64() != 100 {
+		t.Errorf("token transfer in mismatch: %+v", in)
+	}
+	if in.receipt == nil || in.receipt.BlockNumber != 10 || in.receipt.TransactionIndex != 2 {
+		t.Errorf("token transfer receipt mismatch: %+v", in.receipt)
+	}
+	if transfers[1].From != watched {
+		t.Errorf("token transfer out mismatch: %+v", transfers[1])
+	}
+	if e := s.newEnvelope(in, notification.StageConfirmed, ""); e.Type != notification.TypeTokenTransfer || e.Tx.Token == nil {
+		t.Errorf("token transfer envelope mismatch: %+v", e)
+	}
+}
+
+// ChainService is the upstream node of the test with the blocks up to head and the txs and logs of the blocks,
+// all the calls fail if down
+type ChainService struct {
+	lock sync.Mutex
+	head uint64
+	txs  map[uint64]types.Transactions
+	logs map[uint64][]types.Log
+	down bool
+}
+
+var errChainDown = errors.New("chain down")
+
+func (c *ChainService) setDown(down bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.down = down
+}
+
+func (c *ChainService) setHead(head uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.head = head
+}
+
+func chainHeader(number uint64) *types.Header {
+	return &types.Header{
+		ParentHash:  common.BigToHash(new(big.Int).SetUint64(number)),
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(1),
+		Number:      new(big.Int).SetUint64(number),
+		Time:        number,
+		Extra:       []byte{},
+	}
+}
+
+// header returns the header of the block with the txs
+func (c *ChainService) header(number uint64) *types.Header {
+	header := chainHeader(number)
+	if txs := c.txs[number]; len(txs) > 0 {
+		header.TxHash = types.DeriveSha(txs)
+	}
+	return header
+}
+
+func (c *ChainService) GetBlockByNumber(number string, full bool) (map[string]interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.down {
+		return nil, errChainDown
+	}
+	n := c.head
+	if number != "latest" {
+		var err error
+		if n, err = hexutil.DecodeUint64(number); err != nil {
+			return nil, err
+		}
+	}
+	if n > c.head {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(c.header(n))
+	if err != nil {
+		return nil, err
+	}
+	var block map[string]interface{}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = []interface{}{}
+	if txs := c.txs[n]; len(txs) > 0 {
+		block["transactions"] = txs
+	}
+	block["uncles"] = []interface{}{}
+
+	return block, nil
+}
+
+func (c *ChainService) GetLogs(crit map[string]interface{}) ([]types.Log, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.down {
+		return nil, errChainDown
+	}
+	blockHash, _ := crit["blockHash"].(string)
+	for n := uint64(0); n <= c.head; n++ {
+		hash := c.header(n).Hash()
+		if hash.Hex() != blockHash {
+			continue
+		}
+		logs := make([]types.Log, 0, len(c.logs[n]))
+		for _, l := range c.logs[n] {
+			l.BlockNumber, l.BlockHash = n, hash
+			logs = append(logs, l)
+		}
+		return logs, nil
+	}
+
+	return []types.Log{}, nil
+}
+
+func TestScanWatched(t *testing.T) {
+	watched := common.HexToAddress("0xffd639a62be604374ff04af4112a555890bd822a")
+	other := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	token := common.HexToAddress("0xd639a62be604374ff04af4112a555890bd822a03")
+
+	upstream := &ChainService{head: 10, logs: map[uint64][]types.Log{
+		10: {{
+			Address: token,
+			Topics:  []common.Hash{transferEventSig, common.BytesToHash(other.Bytes()), common.BytesToHash(watched.Bytes())},
+			Data:    common.LeftPadBytes(big.NewInt(100).Bytes(), 32),
+			TxHash:  common.HexToHash("0x01"),
+		}},
+	}}
+	upstreamServer := rpc.NewServer()
+	if err := upstreamServer.RegisterName("eth", upstream); err != nil {
+		t.Fatal(err)
+	}
+	upstreamHTTP := httptest.NewServer(upstreamServer)
+	defer upstreamHTTP.Close()
+
+	s, cleanup := newTestStoreServer(t)
+	defer cleanup()
+	s.rpcURL = upstreamHTTP.URL
+	s.networkID = 1007
+	s.txChan = make(chan interface{}, 10)
+	s.notify = &NotifyConfig{WatchConfirmations: 2}
+	if err := s.loadWatched(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.WatchAddress(context.Background(), WatchAddressArgs{Address: utils.Address{Address: watched}}); err != nil {
+		t.Fatal(err)
+	}
+
+	notified := func() []txNotifyWatched {
+		var msgs []txNotifyWatched
+		for len(s.txChan) > 0 {
+			msgs = append(msgs, (<-s.txChan).(txNotifyWatched))
+		}
+		return msgs
+	}
+
+	// the first run starts from the head, the transfer is received but not confirmed yet
+	if err := s.scanWatched(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := notified(); len(msgs) != 1 || msgs[0].confirmed != -1 || *msgs[0].tx.To != watched {
+		t.Fatalf("first run: want received, got %+v", msgs)
+	}
+	if err := s.scanWatched(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := notified(); len(msgs) != 0 {
+		t.Fatalf("no new block: want no notification, got %+v", msgs)
+	}
+
+	// confirmed once the block has enough confirmations
+	upstream.setHead(12)
+	if err := s.scanWatched(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := notified()
+	if len(msgs) != 1 || msgs[0].confirmed != 1 || msgs[0].tx.BlockNumber.Uint64() != 10 {
+		t.Fatalf("confirmed: want the transfer of block 10, got %+v", msgs)
+	}
+
+	// the confirmed block is never notified again
+	upstream.setHead(13)
+	if err := s.scanWatched(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := notified(); len(msgs) != 0 {
+		t.Fatalf("next block: want no notification, got %+v", msgs)
+	}
+}
@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// HeaderAPIKey is the header of the API key, the key can also be the URL path, e.g. http://host/<key>
+	HeaderAPIKey = "X-API-Key"
+
+	maxRequestContentLength = 1024 * 512
+	maxIPLimiters           = 65536
+	reloadInterval          = 5 * time.Second
+)
+
+// sendMethods are the methods counted in the send quota
+var sendMethods = map[string]bool{
+	"newton_sendTransaction":     true,
+	"newton_sendRawTransaction":  true,
+	"newton_sendTransactions":    true,
+	"newton_sendRawTransactions": true,
+	"eth_sendRawTransaction":     true,
+}
+
+// Config is the config of the auth handler
+type Config struct {
+	Enabled     bool
+	KeysFile    string  // the path of the API keys file
+	Required    bool    // reject the requests without API key
+	IPRateLimit float64 // requests per second of each IP, 0 for no limit
+	IPBurst     int     // max requests at once of each IP, default is the rate limit
+}
+
+type sendCount struct {
+	day   string
+	count uint64
+}
+
+// Handler authenticates the API key and limits the rate of the requests before the next handler
+type Handler struct {
+	next   http.Handler
+	config *Config
+
+	lock        sync.Mutex
+	keyFile     *KeyFile
+	modTime     time.Time
+	checkedAt   time.Time
+	keyLimiters map[string]*rate.Limiter
+	ipLimiters  *lru.Cache
+	sends       map[string]*sendCount
+}
+
+// NewHandler creates the auth handler of next
+func NewHandler(next http.Handler, config *Config) (*Handler, error) {
+	ipLimiters, err := lru.New(maxIPLimiters)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		next:        next,
+		config:      config,
+		keyLimiters: make(map[string]*rate.Limiter),
+		ipLimiters:  ipLimiters,
+		sends:       make(map[string]*sendCount),
+	}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// ServeHTTP checks the IP rate limit, the API key, its method permissions, rate limit and send quota
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.next)
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	key, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	// the rpc server also executes the body of the other methods, e.g. OPTIONS, which are not checked
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if key == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestContentLength))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// the body which is not checked is never passed to the rpc server
+	reqs, err := parseRequests(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var sends uint64
+	for _, req := range reqs {
+		if !key.Allows(req.Method) {
+			http.Error(w, fmt.Sprintf("method %s not allowed", req.Method), http.StatusForbidden)
+			return
+		}
+		if sendMethods[req.Method] {
+			sends += countSends(req.Params)
+		}
+	}
+	if sends > 0 && !h.useSendQuota(key, sends) {
+		http.Error(w, "send quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// authenticate checks the IP rate limit, the API key and its rate limit, the error is replied if not ok.
+// The key is nil for the anonymous request if the key is not required.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (*Key, bool) {
+	if !h.allowIP(r.RemoteAddr) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return nil, false
+	}
+
+	apiKey := r.Header.Get(HeaderAPIKey)
+	if path := strings.Trim(r.URL.Path, "/"); apiKey == "" && path != "" {
+		apiKey = path
+		r.URL.Path = "/"
+	}
+	if apiKey == "" {
+		if h.config.Required {
+			http.Error(w, "api key required", http.StatusUnauthorized)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	key, err := h.getKey(apiKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	if !h.allowKey(key) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return nil, false
+	}
+
+	return key, true
+}
+
+// Wrap returns a handler with the same keys and limits of h before the WebSocket handler next.
+// The key is authenticated on the upgrade, and passed in the context of the connection to CallFilter,
+// which checks the permissions, rate limit and send quota of each message.
+func (h *Handler) Wrap(next http.Handler) http.Handler {
+	return &wrappedHandler{h: h, next: next}
+}
+
+type wrappedHandler struct {
+	h    *Handler
+	next http.Handler
+}
+
+// apiKeyContextKey is the context key of the API key of the WebSocket connection
+type apiKeyContextKey struct{}
+
+func (w *wrappedHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	key, ok := w.h.authenticate(rw, r)
+	if !ok {
+		return
+	}
+	if key != nil {
+		r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key.Key))
+	}
+
+	w.next.ServeHTTP(rw, r)
+}
+
+// CallFilter checks the method of the WebSocket message is allowed for the key of the connection,
+// and counts the rate limit and the send quota. The connections without key are not limited.
+func (h *Handler) CallFilter(ctx context.Context, method string, params json.RawMessage) error {
+	apiKey, ok := ctx.Value(apiKeyContextKey{}).(string)
+	if !ok {
+		return nil
+	}
+
+	// the key may be revoked or changed after the connection is opened
+	key, err := h.getKey(apiKey)
+	if err != nil {
+		return err
+	}
+	if !h.allowKey(key) {
+		return errors.New("too many requests")
+	}
+	if !key.Allows(method) {
+		return fmt.Errorf("method %s not allowed", method)
+	}
+	if sendMethods[method] && !h.useSendQuota(key, countSends(params)) {
+		return errors.New("send quota exceeded")
+	}
+
+	return nil
+}
+
+// reload loads the keys file if it is changed, so the keys created or revoked by the admin take effect
+func (h *Handler) reload() error {
+	info, err := os.Stat(h.config.KeysFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil && !info.ModTime().After(h.modTime) && h.keyFile != nil {
+		return nil
+	}
+
+	keyFile, err := LoadKeyFile(h.config.KeysFile)
+	if err != nil {
+		return err
+	}
+	h.keyFile = keyFile
+	if info != nil {
+		h.modTime = info.ModTime()
+	}
+	// the limits of the keys may be changed
+	h.keyLimiters = make(map[string]*rate.Limiter)
+
+	return nil
+}
+
+func (h *Handler) getKey(apiKey string) (*Key, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if time.Since(h.checkedAt) > reloadInterval {
+		h.checkedAt = time.Now()
+		if err := h.reload(); err != nil {
+			// keep the loaded keys
+			logrus.Errorf("Reload api keys error: %v\n", err)
+		}
+	}
+
+	return h.keyFile.Get(apiKey)
+}
+
+func (h *Handler) allowIP(remoteAddr string) bool {
+	if h.config.IPRateLimit <= 0 {
+		return true
+	}
+
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	limiter, ok := h.ipLimiters.Get(ip)
+	if !ok {
+		limiter = newLimiter(h.config.IPRateLimit, h.config.IPBurst)
+		h.ipLimiters.Add(ip, limiter)
+	}
+
+	return limiter.(*rate.Limiter).Allow()
+}
+
+func (h *Handler) allowKey(key *Key) bool {
+	if key.RateLimit <= 0 {
+		return true
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	limiter, ok := h.keyLimiters[key.Key]
+	if !ok {
+		limiter = newLimiter(key.RateLimit, key.Burst)
+		h.keyLimiters[key.Key] = limiter
+	}
+
+	return limiter.Allow()
+}
+
+// useSendQuota counts the sends of the key in today (UTC), returns false if exceeded
+func (h *Handler) useSendQuota(key *Key, n uint64) bool {
+	if key.SendQuota == 0 {
+		return true
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	day := time.Now().UTC().Format("2006-01-02")
+	sc, ok := h.sends[key.Key]
+	if !ok || sc.day != day {
+		sc = &sendCount{day: day}
+		h.sends[key.Key] = sc
+	}
+	if sc.count+n > key.SendQuota {
+		return false
+	}
+	sc.count += n
+
+	return true
+}
+
+func newLimiter(limit float64, burst int) *rate.Limiter {
+	if burst <= 0 {
+		burst = int(limit)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+type rpcRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// parseRequests parses the single or batch JSON-RPC request, the body must be exactly one JSON value,
+// e.g. the trailing bytes are rejected, so the rpc server never executes a request which is not checked
+func parseRequests(body []byte) ([]rpcRequest, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []rpcRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, fmt.Errorf("invalid request: %v", err)
+		}
+		return reqs, nil
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
+	}
+	return []rpcRequest{req}, nil
+}
+
+// countSends returns the number of the txs sent by the request,
+// the batch send methods take [tx, tx, ...] or [[tx, tx, ...]]
+func countSends(params json.RawMessage) uint64 {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return 1
+	}
+
+	var txs []json.RawMessage
+	if len(args) == 1 && json.Unmarshal(args[0], &txs) == nil {
+		return uint64(len(txs))
+	}
+	return uint64(len(args))
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newtonproject/newchain-api-express/rpc"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "newchain-api-express")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "apikeys.json")
+	keyFile, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keyFile.Create("test", []string{"newton_*"}, 0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := keyFile.Create("revoked", nil, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyFile.Revoke(revoked.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyFile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Errorf("path: want /, got %s", r.URL.Path)
+		}
+	})
+	h, err := NewHandler(next, &Config{Enabled: true, KeysFile: path, Required: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		key  string
+		body string
+		code int
+	}{
+		{"no key", "/", "", `{"method":"newton_getBaseInfo"}`, http.StatusUnauthorized},
+		{"revoked key", "/", revoked.Key, `{"method":"newton_getBaseInfo"}`, http.StatusUnauthorized},
+		{"method not allowed", "/", key.Key, `{"method":"eth_call"}`, http.StatusForbidden},
+		{"header key", "/", key.Key, `{"method":"newton_getBaseInfo"}`, http.StatusOK},
+		{"path key", "/" + key.Key, "", `{"method":"newton_getBaseInfo"}`, http.StatusOK},
+		{"send in quota", "/", key.Key, `[{"method":"newton_sendRawTransactions","params":[["0x01","0x02"]]}]`, http.StatusOK},
+		{"send over quota", "/", key.Key, `{"method":"newton_sendRawTransaction","params":{"tx":"0x03"}}`, http.StatusTooManyRequests},
+		{"trailing bytes", "/", key.Key, `{"method":"newton_getBaseInfo"} {"method":"newton_sendRawTransaction","params":{"tx":"0x04"}}`, http.StatusBadRequest},
+		{"invalid body", "/", key.Key, `{"method":`, http.StatusBadRequest},
+		{"not post", "/", key.Key, "", http.StatusMethodNotAllowed},
+		{"not post with body", "/", key.Key, `{"method":"newton_sendRawTransaction","params":{"tx":"0x05"}}`, http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		method := http.MethodPost
+		if strings.HasPrefix(test.name, "not post") {
+			method = http.MethodOptions
+		}
+		r := httptest.NewRequest(method, test.path, strings.NewReader(test.body))
+		if test.key != "" {
+			r.Header.Set(HeaderAPIKey, test.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: want %d, got %d %s", test.name, test.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestKeyAllows(t *testing.T) {
+	key := &Key{Methods: []string{"newton_get*", "eth_call"}}
+	for method, want := range map[string]bool{
+		"newton_getBaseInfo":        true,
+		"newton_sendRawTransaction": false,
+		"eth_call":                  true,
+		"eth_callX":                 false,
+	} {
+		if got := key.Allows(method); got != want {
+			t.Errorf("%s: want %v, got %v", method, want, got)
+		}
+	}
+}
+
+type NewtonService struct{}
+
+func (s *NewtonService) GetBaseInfo() string { return "info" }
+
+func (s *NewtonService) SendTransaction(tx string) string { return tx }
+
+func TestWebsocketCallFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "newchain-api-express")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "apikeys.json")
+	keyFile, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly, err := keyFile.Create("read", []string{"newton_get*"}, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota, err := keyFile.Create("quota", nil, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyFile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("newton", new(NewtonService)); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(rpcServer, &Config{Enabled: true, KeysFile: path, Required: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcServer.SetCallFilter(h.CallFilter)
+	server := httptest.NewServer(h.Wrap(rpcServer.WebsocketHandler([]string{"*"})))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if _, err := rpc.DialWebsocket(context.Background(), wsURL, "http://localhost"); err == nil {
+		t.Error("no key: want error, got nil")
+	}
+
+	tests := []struct {
+		name   string
+		key    *Key
+		method string
+		ok     bool
+	}{
+		{"read allowed", readOnly, "newton_getBaseInfo", true},
+		{"send not allowed", readOnly, "newton_sendTransaction", false},
+		{"send in quota", quota, "newton_sendTransaction", true},
+		{"send over quota", quota, "newton_sendTransaction", false},
+	}
+	for _, test := range tests {
+		client, err := rpc.DialWebsocket(context.Background(), wsURL+"/"+test.key.Key, "http://localhost")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var result string
+		if test.method == "newton_getBaseInfo" {
+			err = client.CallArrayContext(context.Background(), &result, test.method)
+		} else {
+			err = client.CallArrayContext(context.Background(), &result, test.method, "0x01")
+		}
+		client.Close()
+		if (err == nil) != test.ok {
+			t.Errorf("%s: want ok %v, got %v", test.name, test.ok, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package auth provides the API keys, authentication and rate limiting of the express server.
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	errKeyNotFound = errors.New("api key not found")
+	errKeyRevoked  = errors.New("api key revoked")
+)
+
+// Key is an API key of a client
+type Key struct {
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	Methods   []string `json:"methods,omitempty"`   // the allowed methods, e.g. newton_* or eth_call, empty for all
+	RateLimit float64  `json:"rateLimit,omitempty"` // requests per second, 0 for no limit
+	Burst     int      `json:"burst,omitempty"`     // max requests at once, default is the rate limit
+	SendQuota uint64   `json:"sendQuota,omitempty"` // max transactions sent per day, 0 for no limit
+	CreatedAt int64    `json:"createdAt"`
+	Revoked   bool     `json:"revoked,omitempty"`
+}
+
+// Allows returns true if the method is allowed for the key
+func (k *Key) Allows(method string) bool {
+	if len(k.Methods) == 0 {
+		return true
+	}
+	for _, pattern := range k.Methods {
+		if pattern == method || pattern == "*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(method, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeyFile is the API keys stored in a local JSON file
+type KeyFile struct {
+	path string
+	keys []*Key
+}
+
+// LoadKeyFile loads the API keys from the file, the file is created when saved if not exist
+func LoadKeyFile(path string) (*KeyFile, error) {
+	kf := &KeyFile{path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return kf, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &kf.keys); err != nil {
+		return nil, err
+	}
+
+	return kf, nil
+}
+
+// Save writes the API keys to the file
+func (kf *KeyFile) Save() error {
+	data, err := json.MarshalIndent(kf.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(kf.path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	// write to a temp file and rename, so the server never reads a partial file
+	tmp := kf.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, kf.path)
+}
+
+// Keys returns all the API keys, including the revoked
+func (kf *KeyFile) Keys() []*Key {
+	return kf.keys
+}
+
+// Get returns the API key which is not revoked
+func (kf *KeyFile) Get(key string) (*Key, error) {
+	for _, k := range kf.keys {
+		if k.Key != key {
+			continue
+		}
+		if k.Revoked {
+			return nil, errKeyRevoked
+		}
+		return k, nil
+	}
+
+	return nil, errKeyNotFound
+}
+
+// Create adds a new random API key, call Save to persist it
+func (kf *KeyFile) Create(name string, methods []string, rateLimit float64, burst int, sendQuota uint64) (*Key, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	k := &Key{
+		Key:       hex.EncodeToString(b),
+		Name:      name,
+		Methods:   methods,
+		RateLimit: rateLimit,
+		Burst:     burst,
+		SendQuota: sendQuota,
+		CreatedAt: time.Now().Unix(),
+	}
+	kf.keys = append(kf.keys, k)
+
+	return k, nil
+}
+
+// Revoke revokes the API key, call Save to persist it
+func (kf *KeyFile) Revoke(key string) error {
+	for _, k := range kf.keys {
+		if k.Key == key {
+			k.Revoked = true
+			return nil
+		}
+	}
+
+	return errKeyNotFound
+}
@@ -1,14 +1,12 @@
 package cli
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"os"
 
-	"github.com/btcsuite/btcutil/base58"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
@@ -16,13 +14,14 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/newtonproject/newchain-api-express/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 func (cli *CLI) buildAccountCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "account [new|list|balance]",
+		Use:   "account [new|list|balance|mnemonic|derive|xpub]",
 		Short: fmt.Sprintf("Manage %s accounts", cli.blockchain.String()),
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
@@ -36,6 +35,9 @@ func (cli *CLI) buildAccountCmd() *cobra.Command {
 	cmd.AddCommand(cli.buildAccountUpdateCmd())
 	cmd.AddCommand(cli.buildAccountImportCmd())
 	cmd.AddCommand(cli.buildAccountExportCmd())
+	cmd.AddCommand(cli.buildAccountMnemonicCmd())
+	cmd.AddCommand(cli.buildAccountDeriveCmd())
+	cmd.AddCommand(cli.buildAccountXPubCmd())
 
 	if cli.blockchain == NewChain {
 		cmd.AddCommand(cli.buildAccountConvertCmd())
@@ -74,17 +76,18 @@ func (cli *CLI) buildAccountNewCmd() *cobra.Command {
 
 			aList, err := cli.createAccount(wallet, numOfNew)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
-			for _, a := range aList {
-				fmt.Println(a.String())
-				if faucet {
+			if faucet {
+				for _, a := range aList {
 					getFaucet(cli.rpcURL, a.String())
 				}
 			}
 
+			cli.output(&accountListResult{Accounts: aList})
+
 		},
 	}
 
@@ -102,7 +105,7 @@ func (cli *CLI) createAccount(wallet *keystore.KeyStore, numOfNew int) ([]common
 	}
 
 	if numOfNew <= 0 {
-		fmt.Printf("number[%d] of new account less then 1\n", numOfNew)
+		fmt.Fprintf(os.Stderr, "number[%d] of new account less then 1\n", numOfNew)
 		numOfNew = 1
 	}
 
@@ -119,6 +122,11 @@ func (cli *CLI) createAccount(wallet *keystore.KeyStore, numOfNew int) ([]common
 	return aList, nil
 }
 
+// accountListResult is the accounts in the wallet path, or the accounts created by account new
+type accountListResult struct {
+	Accounts []common.Address `json:"accounts"`
+}
+
 func (cli *CLI) buildAccountListCmd() *cobra.Command {
 	accountListCmd := &cobra.Command{
 		Use:                   "list",
@@ -130,19 +138,33 @@ func (cli *CLI) buildAccountListCmd() *cobra.Command {
 				keystore.LightScryptN, keystore.LightScryptP)
 
 			if len(wallet.Accounts()) == 0 {
-				fmt.Println("empty wallet, create account first")
+				cli.fail(errors.New("empty wallet, create account first"))
 				return
 			}
 
+			result := &accountListResult{Accounts: make([]common.Address, 0, len(wallet.Accounts()))}
 			for _, account := range wallet.Accounts() {
-				fmt.Println(account.Address.Hex())
+				result.Accounts = append(result.Accounts, account.Address)
 			}
+			cli.output(result)
 		},
 	}
 
 	return accountListCmd
 }
 
+// convertResult is the addresses converted between the hex and the NEW format
+type convertResult struct {
+	Addresses []convertedAddress `json:"addresses"`
+}
+
+type convertedAddress struct {
+	Input      string          `json:"input"`
+	Address    *common.Address `json:"address,omitempty"`
+	NEWAddress string          `json:"newAddress,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
 func (cli *CLI) buildAccountConvertCmd() *cobra.Command {
 	accountListCmd := &cobra.Command{
 		Use:                   "convert",
@@ -154,30 +176,40 @@ func (cli *CLI) buildAccountConvertCmd() *cobra.Command {
 
 			client, err := ethclient.Dial(cli.rpcURL)
 			if err != nil {
-				fmt.Printf("Error: build client error(%v)\n", err)
+				cli.fail(fmt.Errorf("build client error: %v", err))
 				return
 			}
 
 			chainID, err := client.NetworkID(context.Background())
 			if err != nil {
-				fmt.Printf("Error: get chainID error(%v)\n", err)
+				cli.fail(fmt.Errorf("get chainID error: %v", err))
 				return
 			}
 
+			result := &convertResult{Addresses: make([]convertedAddress, 0, len(args))}
 			for _, addressStr := range args {
 				if common.IsHexAddress(addressStr) {
 					address := common.HexToAddress(addressStr)
-					fmt.Println(address.String(), addressToNew(chainID.Bytes(), address))
+					result.Addresses = append(result.Addresses, convertedAddress{
+						Input:      addressStr,
+						Address:    &address,
+						NEWAddress: utils.AddressToNew(chainID.Uint64(), address),
+					})
 					continue
 				}
 
-				address, err := newToAddress(chainID.Bytes(), addressStr)
+				address, err := utils.NewToAddress(chainID.Uint64(), addressStr)
 				if err != nil {
-					fmt.Println(err, addressStr)
+					result.Addresses = append(result.Addresses, convertedAddress{Input: addressStr, Error: err.Error()})
 					continue
 				}
-				fmt.Println(address.String(), addressStr)
+				result.Addresses = append(result.Addresses, convertedAddress{
+					Input:      addressStr,
+					Address:    &address,
+					NEWAddress: addressStr,
+				})
 			}
+			cli.output(result)
 
 		},
 	}
@@ -185,6 +217,11 @@ func (cli *CLI) buildAccountConvertCmd() *cobra.Command {
 	return accountListCmd
 }
 
+// accountResult is the account updated or imported
+type accountResult struct {
+	Address common.Address `json:"address"`
+}
+
 func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
 	accountNewCmd := &cobra.Command{
 		Use:                   "update <address> [-s]",
@@ -203,25 +240,22 @@ func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
 					keystore.LightScryptN, keystore.LightScryptP)
 			}
 
-			addrStr := args[0]
-			var address common.Address
-			if !common.IsHexAddress(addrStr) {
-				fmt.Println("Error: No accounts specified to update")
+			address, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("no accounts specified to update: %v", err))
 				return
 			}
-			address = common.HexToAddress(addrStr)
 			account := accounts.Account{Address: address}
 
 			if account.Address == (common.Address{}) {
-				fmt.Println("Error: ", errRequiredFromAddress)
+				cli.fail(errRequiredFromAddress)
 				return
 			}
 			if _, err := wallet.Find(account); err != nil {
-				fmt.Printf("Error: %v (%s)\n", err, account.Address.String())
+				cli.fail(fmt.Errorf("%v (%s)", err, account.Address.String()))
 				return
 			}
 
-			var err error
 			walletPassword := ""
 			var trials int
 			for trials = 0; trials < 3; trials++ {
@@ -229,7 +263,7 @@ func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
 				if walletPassword == "" {
 					walletPassword, _ = getPassPhrase(prompt, false)
 				} else {
-					fmt.Println(prompt, "\nUse the the password has set")
+					fmt.Fprintln(os.Stderr, prompt, "\nUse the the password has set")
 				}
 				err = wallet.Unlock(account, walletPassword)
 				if err == nil {
@@ -239,26 +273,22 @@ func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
 			}
 
 			if trials >= 3 {
-				if err != nil {
-					fmt.Println("Error: unlock account error: ", err)
-					return
-				}
-				fmt.Printf("Error: failed to unlock account %s (%v)\n", account.Address.String(), err)
+				cli.fail(fmt.Errorf("failed to unlock account %s (%v)", account.Address.String(), err))
 				return
 			}
 
 			newWalletPassword, err := getPassPhrase("Please give a new password. Do not forget this password.", true)
 			if err != nil {
-				fmt.Println("Error: getPassPhrase error: ", err)
+				cli.fail(err)
 				return
 			}
 
 			if err := wallet.Update(account, walletPassword, newWalletPassword); err != nil {
-				fmt.Println("Error: udpate account error: ", err)
+				cli.fail(fmt.Errorf("update account error: %v", err))
 				return
 			}
 
-			fmt.Println("Successfully updated the account: ", account.Address.String())
+			cli.output(&accountResult{Address: account.Address})
 		},
 	}
 
@@ -266,35 +296,6 @@ func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
 	return accountNewCmd
 }
 
-func addressToNew(chainID []byte, address common.Address) string {
-	input := append(chainID, address.Bytes()...)
-	return "NEW" + base58.CheckEncode(input, 0)
-}
-
-func newToAddress(chainID []byte, newAddress string) (common.Address, error) {
-	if newAddress[:3] != "NEW" {
-		return common.Address{}, errors.New("not NEW address")
-	}
-
-	decoded, version, err := base58.CheckDecode(newAddress[3:])
-	if err != nil {
-		return common.Address{}, err
-	}
-	if version != 0 {
-		return common.Address{}, errors.New("illegal version")
-	}
-	if len(decoded) < 20 {
-		return common.Address{}, errors.New("illegal decoded length")
-	}
-	if !bytes.Equal(decoded[:len(decoded)-20], chainID) {
-		return common.Address{}, errors.New("illegal ChainID")
-	}
-
-	address := common.BytesToAddress(decoded[len(decoded)-20:])
-
-	return address, nil
-}
-
 func (cli *CLI) buildAccountImportCmd() *cobra.Command {
 	accountListCmd := &cobra.Command{
 		Use:                   "import",
@@ -305,7 +306,7 @@ func (cli *CLI) buildAccountImportCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			hexkey, err := console.Stdin.PromptPassword("Enter private key: ")
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 			hexkeylen := len(hexkey)
@@ -317,7 +318,7 @@ func (cli *CLI) buildAccountImportCmd() *cobra.Command {
 
 			pkey, err := crypto.HexToECDSA(hexkey)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
@@ -326,16 +327,16 @@ func (cli *CLI) buildAccountImportCmd() *cobra.Command {
 
 			walletPassword, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true)
 			if err != nil {
-				fmt.Println("Error: ", err)
+				cli.fail(err)
 				return
 			}
 
 			a, err := wallet.ImportECDSA(pkey, walletPassword)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
-			fmt.Println(a.Address.String())
+			cli.output(&accountResult{Address: a.Address})
 
 		},
 	}
@@ -343,6 +344,12 @@ func (cli *CLI) buildAccountImportCmd() *cobra.Command {
 	return accountListCmd
 }
 
+// accountExportResult is the hex private key of the exported account
+type accountExportResult struct {
+	Address    common.Address `json:"address"`
+	PrivateKey string         `json:"privateKey"`
+}
+
 func (cli *CLI) buildAccountExportCmd() *cobra.Command {
 	accountListCmd := &cobra.Command{
 		Use:                   "export <hexAddress>",
@@ -352,40 +359,36 @@ func (cli *CLI) buildAccountExportCmd() *cobra.Command {
 		Hidden:                true,
 		Run: func(cmd *cobra.Command, args []string) {
 
-			hexAddress := args[0]
-			if !common.IsHexAddress(hexAddress) {
-				fmt.Printf("Error: %s is not valid hex-encoded address\n", hexAddress)
+			address, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("%s is not valid address: %v", args[0], err))
 				return
 			}
-
-			address := common.HexToAddress(hexAddress)
 			account := accounts.Account{Address: address}
 
 			wallet := keystore.NewKeyStore(cli.walletPath,
 				keystore.LightScryptN, keystore.LightScryptP)
 
 			if !wallet.HasAddress(address) {
-				fmt.Println("The given address is not present")
+				cli.fail(errors.New("the given address is not present"))
 				return
 			}
 
-			var err error
-
 			prompt := fmt.Sprintf("Unlocking account %s", account.Address.String())
 			walletPassword, _ := getPassPhrase(prompt, false)
 			keyJSON, err := wallet.Export(account, walletPassword, walletPassword)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
 			key, err := keystore.DecryptKey(keyJSON, walletPassword)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
-			fmt.Println(common.ToHex(key.PrivateKey.D.Bytes()))
+			cli.output(&accountExportResult{Address: address, PrivateKey: common.ToHex(key.PrivateKey.D.Bytes())})
 
 		},
 	}
@@ -393,30 +396,39 @@ func (cli *CLI) buildAccountExportCmd() *cobra.Command {
 	return accountListCmd
 }
 
+// balanceResult is the balances of the addresses and the total, the amounts are in NEW
+type balanceResult struct {
+	Count    int              `json:"count"`
+	Total    string           `json:"total"`
+	Accounts []accountBalance `json:"accounts"`
+}
+
+type accountBalance struct {
+	Address common.Address `json:"address"`
+	Balance string         `json:"balance,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
 func (cli *CLI) buildAccountBalanceCmd() *cobra.Command {
 	accountBalanceCmd := &cobra.Command{
-		Use:                   "balance [hexAddress]",
+		Use:                   "balance [address]",
 		Short:                 "get balance of address",
 		DisableFlagsInUseLine: true,
 		Run: func(cmd *cobra.Command, args []string) {
-			cli.showBalance(cmd, args, true)
-
+			result, err := cli.getBalances(args)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			cli.output(result)
 		},
 	}
 
 	return accountBalanceCmd
 }
 
-func (cli *CLI) showBalance(cmd *cobra.Command, args []string, showSum bool) {
-	var err error
-
-	unit, _ := cmd.Flags().GetString("unit")
-	if unit != "" && !stringInSlice(unit, UnitList) {
-		fmt.Printf("Unit(%s) for invalid. %s.\n", unit, UnitString)
-		fmt.Fprint(os.Stderr, cmd.UsageString())
-		return
-	}
-
+// getBalances returns the balances of the addresses, or all the accounts in the wallet if no address
+func (cli *CLI) getBalances(args []string) (*balanceResult, error) {
 	var addressList []common.Address
 
 	if len(args) <= 0 {
@@ -429,35 +441,40 @@ func (cli *CLI) showBalance(cmd *cobra.Command, args []string, showSum bool) {
 
 	} else {
 		for _, addressStr := range args {
-			addressList = append(addressList, common.HexToAddress(addressStr))
+			address, err := parseAddress(addressStr)
+			if err != nil {
+				return nil, fmt.Errorf("address[%s] error: %v", addressStr, err)
+			}
+			addressList = append(addressList, address)
 		}
 	}
 
 	client, err := newtonclient.Dial(viper.GetString("Client.RPCUrl"))
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, err
 	}
 	ctx := context.Background()
 
+	infos, err := client.GetBaseInfos(ctx, addressList)
+	if err != nil {
+		return nil, fmt.Errorf("GetBaseInfos error: %v", err)
+	}
+
+	result := &balanceResult{Count: len(addressList), Accounts: make([]accountBalance, 0, len(infos))}
 	balanceSum := big.NewInt(0)
-	for _, address := range addressList {
-		info, err := client.GetBaseInfo(ctx, address)
-		if err != nil {
-			fmt.Println("GetBaseInfo error:", err)
-			return
+	for _, info := range infos {
+		if info.Error != nil {
+			result.Accounts = append(result.Accounts, accountBalance{Address: info.Address, Error: info.Error.Error()})
+			continue
 		}
-		balance := info.Balance
-
-		balanceSum.Add(balanceSum, balance)
-
-		fmt.Printf("Address[%s] Balance[%s]\n", address.Hex(), getWeiAmountTextUnitByUnit(balance, unit))
-	}
 
-	if showSum {
-		fmt.Println("Number Of Accounts:", len(addressList))
-		fmt.Println("Total Balance:", getWeiAmountTextUnitByUnit(balanceSum, unit))
+		balanceSum.Add(balanceSum, info.Balance)
+		result.Accounts = append(result.Accounts, accountBalance{
+			Address: info.Address,
+			Balance: getWeiAmountTextByUnit(info.Balance, UnitETH),
+		})
 	}
+	result.Total = getWeiAmountTextByUnit(balanceSum, UnitETH)
 
-	return
+	return result, nil
 }
@@ -3,8 +3,12 @@ package cli
 import (
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 
 	"github.com/newtonproject/newchain-api-express/api"
+	"github.com/newtonproject/newchain-api-express/auth"
+	"github.com/newtonproject/newchain-api-express/notification"
 	"github.com/newtonproject/newchain-api-express/rpc"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,15 +32,17 @@ func (cli *CLI) buildServerCmd() *cobra.Command {
 				hostAddress = "127.0.0.1:8888"
 			}
 
-			log.Printf("Listening at %v...", hostAddress)
+			viper.SetDefault("DataDir", defaultDataDir)
+			dataDir := viper.GetString("DataDir")
 
-			notify, err := loadNotifyConfig()
+			notify, err := loadNotifyConfig(dataDir)
 			if err != nil {
 				log.Println(err)
 				return
 			}
 
-			s, err := api.NewServer(cli.rpcURL, notify)
+			metrics := api.NewMetrics()
+			s, err := api.NewServer(cli.rpcURL, notify, dataDir, loadIndexerConfig(), loadHealthConfig(), viper.GetBool("NewAddress"), metrics)
 			if err != nil {
 				log.Println(err)
 				return
@@ -48,8 +54,82 @@ func (cli *CLI) buildServerCmd() *cobra.Command {
 				log.Println(err)
 				return
 			}
+			rpcServer.SetCallObserver(metrics.ObserveCall)
+
+			if proxyConfig := loadProxyConfig(); proxyConfig.Enabled {
+				proxy, err := api.NewProxy(cli.rpcURL, proxyConfig, metrics)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				rpcServer.SetProxy(proxy)
+			}
+
+			var handler http.Handler = rpcServer
+			var authHandler *auth.Handler
+			if authConfig := loadAuthConfig(); authConfig.Enabled {
+				authHandler, err = auth.NewHandler(handler, authConfig)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				handler = authHandler
+				// the WebSocket messages are checked by the keys of the connections
+				rpcServer.SetCallFilter(authHandler.CallFilter)
+			}
+
+			httpConfig := loadHTTPConfig()
+
+			// serve the probes and metrics before the auth handler, the paths are not API keys
+			mux := http.NewServeMux()
+			mux.Handle(healthzPath, api.HealthHandler(s))
+			mux.Handle(readyzPath, api.ReadyHandler(s))
+			if metricsConfig := loadMetricsConfig(); metricsConfig.Enabled {
+				if metricsConfig.Host == "" {
+					mux.Handle(metricsPath, metrics.Handler())
+				} else {
+					metricsMux := http.NewServeMux()
+					metricsMux.Handle(metricsPath, metrics.Handler())
+					metricsServer := &http.Server{Addr: metricsConfig.Host, Handler: metricsMux}
+					go func() {
+						log.Printf("Metrics listening at %v%v...", metricsConfig.Host, metricsPath)
+						if err := metricsServer.ListenAndServe(); err != nil {
+							log.Println(err)
+						}
+					}()
+				}
+			}
+			mux.Handle("/", handler)
+			handler = mux
+
+			if wsConfig := loadWSConfig(); wsConfig.Enabled {
+				var wsHandler http.Handler = rpcServer.WebsocketHandler(wsConfig.Origins)
+				if authHandler != nil {
+					wsHandler = authHandler.Wrap(wsHandler)
+				}
+				wsServer := &http.Server{Addr: wsConfig.Host, Handler: wsHandler}
+				go func() {
+					log.Printf("WebSocket listening at %v...", wsConfig.Host)
+					if err := listenAndServe(wsServer, httpConfig.TLSCert, httpConfig.TLSKey); err != nil {
+						log.Println(err)
+					}
+				}()
+			}
 
-			err = http.ListenAndServe(hostAddress, rpcServer)
+			if ipcPath := viper.GetString("IPCPath"); ipcPath != "" {
+				listener, err := rpc.IPCListen(ipcPath)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				log.Printf("IPC listening at %v...", ipcPath)
+				go rpcServer.ServeListener(listener)
+			}
+
+			httpServer := rpc.NewHTTPServerWithHandler(httpConfig.CORS, httpConfig.VHosts, httpConfig.Timeouts, handler)
+			httpServer.Addr = hostAddress
+			log.Printf("Listening at %v...", hostAddress)
+			err = listenAndServe(httpServer, httpConfig.TLSCert, httpConfig.TLSKey)
 			if err != nil {
 				log.Println(err)
 				return
@@ -62,7 +142,89 @@ func (cli *CLI) buildServerCmd() *cobra.Command {
 	return cmd
 }
 
-func loadNotifyConfig() (*api.NotifyConfig, error) {
+// httpConfig is the config of the HTTP listener
+type httpConfig struct {
+	CORS     []string
+	VHosts   []string
+	Timeouts rpc.HTTPTimeouts
+	TLSCert  string
+	TLSKey   string
+}
+
+func loadHTTPConfig() *httpConfig {
+	p := "HTTP"
+
+	viper.SetDefault(p+".VHosts", []string{"*"})
+	viper.SetDefault(p+".ReadTimeout", rpc.DefaultHTTPTimeouts.ReadTimeout)
+	viper.SetDefault(p+".WriteTimeout", defaultWriteTimeout)
+	viper.SetDefault(p+".IdleTimeout", rpc.DefaultHTTPTimeouts.IdleTimeout)
+
+	return &httpConfig{
+		CORS:   viper.GetStringSlice(p + ".CORS"),
+		VHosts: viper.GetStringSlice(p + ".VHosts"),
+		Timeouts: rpc.HTTPTimeouts{
+			ReadTimeout:  viper.GetDuration(p + ".ReadTimeout"),
+			WriteTimeout: viper.GetDuration(p + ".WriteTimeout"),
+			IdleTimeout:  viper.GetDuration(p + ".IdleTimeout"),
+		},
+		TLSCert: viper.GetString(p + ".TLSCert"),
+		TLSKey:  viper.GetString(p + ".TLSKey"),
+	}
+}
+
+// wsConfig is the config of the WebSocket listener
+type wsConfig struct {
+	Enabled bool
+	Host    string
+	Origins []string
+}
+
+func loadWSConfig() *wsConfig {
+	p := "WS"
+
+	viper.SetDefault(p+".Host", defaultWSHost)
+	viper.SetDefault(p+".Origins", []string{"*"})
+
+	return &wsConfig{
+		Enabled: viper.GetBool(p + ".Enabled"),
+		Host:    viper.GetString(p + ".Host"),
+		Origins: viper.GetStringSlice(p + ".Origins"),
+	}
+}
+
+// metricsConfig is the config of the Prometheus metrics endpoint
+type metricsConfig struct {
+	Enabled bool
+	Host    string // serve the metrics on a separate listener, or on the HTTP listener if empty
+}
+
+func loadMetricsConfig() *metricsConfig {
+	p := "Metrics"
+
+	return &metricsConfig{
+		Enabled: viper.GetBool(p + ".Enabled"),
+		Host:    viper.GetString(p + ".Host"),
+	}
+}
+
+func loadHealthConfig() *api.HealthConfig {
+	p := "Health"
+
+	return &api.HealthConfig{
+		MaxHeadAge:    viper.GetDuration(p + ".MaxHeadAge"),
+		MaxQueueUsage: viper.GetFloat64(p + ".MaxQueueUsage"),
+	}
+}
+
+// listenAndServe serves HTTPS if the cert and key are set, otherwise HTTP
+func listenAndServe(server *http.Server, certFile, keyFile string) error {
+	if certFile != "" && keyFile != "" {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	}
+	return server.ListenAndServe()
+}
+
+func loadNotifyConfig(dataDir string) (*api.NotifyConfig, error) {
 	p := "Notify"
 
 	server := viper.GetString(p + ".Server")
@@ -88,6 +250,21 @@ func loadNotifyConfig() (*api.NotifyConfig, error) {
 
 	prefixTopic := viper.GetString(p + ".PrefixTopic")
 
+	viper.SetDefault(p+".Encoding", api.EncodingLegacy)
+	encoding := viper.GetString(p + ".Encoding")
+	if encoding != api.EncodingLegacy && !notification.ValidEncoding(encoding) {
+		return nil, fmt.Errorf("%s Encoding only legacy,json,cbor", p)
+	}
+
+	// the store of the messages is a sibling of the database, which owns the files in DataDir
+	storeDir := viper.GetString(p + ".StoreDir")
+	if storeDir == "" {
+		storeDir = filepath.Clean(dataDir) + "-mqtt"
+	}
+	if rel, err := filepath.Rel(dataDir, storeDir); err == nil && !strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("%s StoreDir %s is inside DataDir %s", p, storeDir, dataDir)
+	}
+
 	return &api.NotifyConfig{
 		Server:      server,
 		Username:    username,
@@ -95,5 +272,36 @@ func loadNotifyConfig() (*api.NotifyConfig, error) {
 		ClientID:    clientID,
 		QoS:         byte(qos),
 		PrefixTopic: prefixTopic,
+
+		CAFile:             viper.GetString(p + ".CAFile"),
+		CertFile:           viper.GetString(p + ".CertFile"),
+		KeyFile:            viper.GetString(p + ".KeyFile"),
+		InsecureSkipVerify: viper.GetBool(p + ".InsecureSkipVerify"),
+
+		StoreDir:             storeDir,
+		MaxReconnectInterval: viper.GetDuration(p + ".MaxReconnectInterval"),
+		PublishTimeout:       viper.GetDuration(p + ".PublishTimeout"),
+		RetainStatus:         viper.GetBool(p + ".RetainStatus"),
+		Encoding:             encoding,
+		WatchConfirmations:   viper.GetUint64(p + ".WatchConfirmations"),
 	}, nil
 }
+
+func loadIndexerConfig() *api.IndexerConfig {
+	p := "Indexer"
+
+	return &api.IndexerConfig{
+		Enabled:       viper.GetBool(p + ".Enabled"),
+		StartHeight:   viper.GetUint64(p + ".StartHeight"),
+		Confirmations: viper.GetUint64(p + ".Confirmations"),
+	}
+}
+
+func loadProxyConfig() *api.ProxyConfig {
+	p := "Proxy"
+
+	return &api.ProxyConfig{
+		Enabled: viper.GetBool(p + ".Enabled"),
+		Methods: viper.GetStringSlice(p + ".Methods"),
+	}
+}
@@ -1,9 +1,33 @@
 package cli
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
 
 func TestForce(t *testing.T) {
 	cli := NewCLI()
 
 	cli.TestCommand("escrow")
 }
+
+func TestNotifyStoreDir(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("Notify.Server", "tcp://127.0.0.1:6883")
+	viper.Set("Notify.Username", "username")
+	viper.Set("Notify.Password", "password")
+
+	config, err := loadNotifyConfig("./data/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.StoreDir != "data-mqtt" {
+		t.Errorf("default store dir: want data-mqtt, got %s", config.StoreDir)
+	}
+
+	viper.Set("Notify.StoreDir", "./data/mqtt")
+	if _, err := loadNotifyConfig("./data"); err == nil {
+		t.Error("store dir inside the data dir: want error")
+	}
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"strings"
+	"time"
+
+	"github.com/newtonproject/newchain-api-express/auth"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func (cli *CLI) buildAPIKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey [create|revoke|list]",
+		Short: "Manage the API keys of the server",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			return
+		},
+	}
+
+	cmd.AddCommand(cli.buildAPIKeyCreateCmd())
+	cmd.AddCommand(cli.buildAPIKeyRevokeCmd())
+	cmd.AddCommand(cli.buildAPIKeyListCmd())
+
+	return cmd
+}
+
+// apiKeyResult is the API key, the methods are * if all allowed
+type apiKeyResult struct {
+	Key     string  `json:"key"`
+	Name    string  `json:"name"`
+	Methods string  `json:"methods"`
+	Rate    float64 `json:"rate"`
+	Burst   int     `json:"burst"`
+	Quota   uint64  `json:"quota"`
+	Created string  `json:"created"`
+	Revoked bool    `json:"revoked"`
+}
+
+func newAPIKeyResult(key *auth.Key) *apiKeyResult {
+	methods := "*"
+	if len(key.Methods) > 0 {
+		methods = strings.Join(key.Methods, ",")
+	}
+
+	return &apiKeyResult{
+		Key:     key.Key,
+		Name:    key.Name,
+		Methods: methods,
+		Rate:    key.RateLimit,
+		Burst:   key.Burst,
+		Quota:   key.SendQuota,
+		Created: time.Unix(key.CreatedAt, 0).Format(time.RFC3339),
+		Revoked: key.Revoked,
+	}
+}
+
+type apiKeyRevokeResult struct {
+	Key     string `json:"key"`
+	Revoked bool   `json:"revoked"`
+}
+
+type apiKeyListResult struct {
+	Keys []*apiKeyResult `json:"keys"`
+}
+
+func (cli *CLI) buildAPIKeyCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "create <name> [--methods newton_*,eth_call] [--rate n] [--burst n] [--quota n]",
+		Short:                 "Create a new API key",
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			keyFile, err := auth.LoadKeyFile(apiKeysFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			methods, _ := cmd.Flags().GetStringSlice("methods")
+			rateLimit, _ := cmd.Flags().GetFloat64("rate")
+			burst, _ := cmd.Flags().GetInt("burst")
+			quota, _ := cmd.Flags().GetUint64("quota")
+
+			key, err := keyFile.Create(args[0], methods, rateLimit, burst, quota)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			if err := keyFile.Save(); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(newAPIKeyResult(key))
+		},
+	}
+
+	cmd.Flags().StringSlice("methods", nil, "the allowed `methods`, e.g. newton_*,eth_call, default is all")
+	cmd.Flags().Float64("rate", 0, "the rate limit in requests per second, 0 for no limit")
+	cmd.Flags().Int("burst", 0, "the max requests at once, default is the rate limit")
+	cmd.Flags().Uint64("quota", 0, "the max transactions sent per day, 0 for no limit")
+
+	return cmd
+}
+
+func (cli *CLI) buildAPIKeyRevokeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "revoke <key>",
+		Short:                 "Revoke the API key",
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			keyFile, err := auth.LoadKeyFile(apiKeysFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			if err := keyFile.Revoke(args[0]); err != nil {
+				cli.fail(err)
+				return
+			}
+			if err := keyFile.Save(); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(&apiKeyRevokeResult{Key: args[0], Revoked: true})
+		},
+	}
+
+	return cmd
+}
+
+func (cli *CLI) buildAPIKeyListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "list",
+		Short:                 "List all the API keys",
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			keyFile, err := auth.LoadKeyFile(apiKeysFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			result := &apiKeyListResult{Keys: []*apiKeyResult{}}
+			for _, key := range keyFile.Keys() {
+				result.Keys = append(result.Keys, newAPIKeyResult(key))
+			}
+
+			cli.output(result)
+		},
+	}
+
+	return cmd
+}
+
+func apiKeysFile() string {
+	viper.SetDefault("Auth.KeysFile", defaultAPIKeysFile)
+	return viper.GetString("Auth.KeysFile")
+}
+
+func loadAuthConfig() *auth.Config {
+	p := "Auth"
+
+	return &auth.Config{
+		Enabled:     viper.GetBool(p + ".Enabled"),
+		KeysFile:    apiKeysFile(),
+		Required:    viper.GetBool(p + ".Required"),
+		IPRateLimit: viper.GetFloat64(p + ".IPRateLimit"),
+		IPBurst:     viper.GetInt(p + ".IPBurst"),
+	}
+}
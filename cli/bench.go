@@ -0,0 +1,823 @@
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/newtonproject/newchain-api-express/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the methods of bench
+const (
+	benchMethodRaw = "raw" // newton_sendRawTransaction
+	benchMethodTx  = "tx"  // newton_sendTransaction
+)
+
+var benchMethodNames = map[string]string{
+	benchMethodRaw: "newton_sendRawTransaction",
+	benchMethodTx:  "newton_sendTransaction",
+}
+
+// maxBenchAccounts is the max addresses of newton_getBaseInfos
+const maxBenchAccounts = 1000
+
+// benchResult is the report of the runs, the latencies are in milliseconds
+type benchResult struct {
+	Accounts    int          `json:"accounts"`
+	TxsPerRun   int          `json:"txsPerRun"`
+	Concurrency int          `json:"concurrency"`
+	Runs        []benchRun   `json:"runs"`
+	Errors      []benchError `json:"errors,omitempty"`
+}
+
+// benchRun is the result of the txs sent by the method at the wait level
+type benchRun struct {
+	Method    string  `json:"method"`
+	Wait      uint64  `json:"wait"`
+	Sent      int     `json:"sent"`
+	Failed    int     `json:"failed"`
+	Duration  string  `json:"duration"`
+	TPS       float64 `json:"tps"`
+	Response  latency `json:"response"`  // the response time of the API
+	Broadcast latency `json:"broadcast"` // from sent to the broadcast notification
+	Confirmed latency `json:"confirmed"` // from sent to the confirmed notification
+}
+
+// benchRefundResult is the result of bench refund
+type benchRefundResult struct {
+	Funder   common.Address `json:"funder"`
+	Accounts int            `json:"accounts"`
+}
+
+// benchError is the count of the same error of the run
+type benchError struct {
+	Method string `json:"method"`
+	Wait   uint64 `json:"wait"`
+	Count  int    `json:"count"`
+	Error  string `json:"error"`
+}
+
+// latency is the percentiles of the durations in milliseconds
+type latency struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+func newLatency(durations []time.Duration) latency {
+	if len(durations) == 0 {
+		return latency{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return latency{
+		Count: len(sorted),
+		P50:   milliseconds(percentile(sorted, 50)),
+		P90:   milliseconds(percentile(sorted, 90)),
+		P99:   milliseconds(percentile(sorted, 99)),
+		Max:   milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// String returns p50/p90/p99/max for the table
+func (l latency) String() string {
+	if l.Count == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%g/%g/%g/%gms", l.P50, l.P90, l.P99, l.Max)
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+}
+
+// defaultBenchKeysFile is the file of the keys of the test accounts
+const defaultBenchKeysFile = "./bench-keys.json"
+
+// benchAccount is the generated test account, the key is saved to the keys file before funding
+type benchAccount struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	txs     []*benchTx
+}
+
+// benchKeys is the keys file, so the test accounts can be refunded after the bench is interrupted
+type benchKeys struct {
+	Funder common.Address  `json:"funder"`
+	Keys   []hexutil.Bytes `json:"keys"`
+}
+
+// saveBenchKeys saves the keys of the test accounts, the existing file is never overwritten
+// as its accounts may not be refunded yet
+func saveBenchKeys(path string, funder common.Address, benchAccounts []*benchAccount) error {
+	keys := &benchKeys{Funder: funder, Keys: make([]hexutil.Bytes, len(benchAccounts))}
+	for i, a := range benchAccounts {
+		keys.Keys[i] = crypto.FromECDSA(a.key)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("the keys file %s exists, run bench refund first", path)
+	} else if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadBenchKeys loads the funder and the test accounts of the keys file
+func loadBenchKeys(path string) (common.Address, []*benchAccount, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	var keys benchKeys
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return common.Address{}, nil, err
+	}
+
+	benchAccounts := make([]*benchAccount, len(keys.Keys))
+	for i, k := range keys.Keys {
+		key, err := crypto.ToECDSA(k)
+		if err != nil {
+			return common.Address{}, nil, err
+		}
+		benchAccounts[i] = &benchAccount{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+
+	return keys.Funder, benchAccounts, nil
+}
+
+// benchTx is the pre-signed transfer and the result of sending it
+type benchTx struct {
+	tx        *types.Transaction // the signed tx
+	rlpTx     []byte             // the unsigned RLP of newton_sendTransaction
+	signature []byte             // [R || S] of newton_sendTransaction
+
+	response time.Duration
+	err      error
+}
+
+// benchTracker records the sent time of the txs and the latencies of the notifications
+type benchTracker struct {
+	lock      sync.Mutex
+	sent      map[common.Hash]time.Time
+	broadcast map[common.Hash]time.Duration
+	confirmed map[common.Hash]time.Duration
+}
+
+func newBenchTracker() *benchTracker {
+	return &benchTracker{
+		sent:      make(map[common.Hash]time.Time),
+		broadcast: make(map[common.Hash]time.Duration),
+		confirmed: make(map[common.Hash]time.Duration),
+	}
+}
+
+// start records the sent time, before the tx is sent so the notification never comes first
+func (t *benchTracker) start(hash common.Hash) time.Time {
+	now := time.Now()
+	t.lock.Lock()
+	t.sent[hash] = now
+	t.lock.Unlock()
+	return now
+}
+
+// observe records the first notification of the stage of the sent tx
+func (t *benchTracker) observe(event *watchEvent) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	sent, ok := t.sent[event.Hash]
+	if !ok {
+		return
+	}
+	var latencies map[common.Hash]time.Duration
+	switch event.Stage {
+	case notification.StageBroadcast:
+		latencies = t.broadcast
+	case notification.StageConfirmed:
+		latencies = t.confirmed
+	default:
+		return
+	}
+	if _, ok := latencies[event.Hash]; !ok {
+		latencies[event.Hash] = time.Since(sent)
+	}
+}
+
+// latencies returns the broadcast and confirmed latencies of the txs
+func (t *benchTracker) latencies(hashes []common.Hash) (broadcast, confirmed []time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, hash := range hashes {
+		if d, ok := t.broadcast[hash]; ok {
+			broadcast = append(broadcast, d)
+		}
+		if d, ok := t.confirmed[hash]; ok {
+			confirmed = append(confirmed, d)
+		}
+	}
+	return broadcast, confirmed
+}
+
+// waitConfirmed waits all the txs are notified as confirmed, or timeout
+func (t *benchTracker) waitConfirmed(ctx context.Context, hashes []common.Hash, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, confirmed := t.latencies(hashes)
+		if len(confirmed) == len(hashes) {
+			return true
+		}
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// signBenchTx signs the tx with the key, the recovery ID is searched as the server does
+func signBenchTx(key *ecdsa.PrivateKey, tx *types.Transaction, signer types.Signer) (*benchTx, error) {
+	hash := signer.Hash(tx).Bytes()
+	sign, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := utils.RecoverSignature(hash, sign, crypto.PubkeyToAddress(key.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+	rlpTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &benchTx{tx: signedTx, rlpTx: rlpTx, signature: signature[:64]}, nil
+}
+
+// fundBenchAccounts unlocks the funder once and pays the amount to each account, waiting to be confirmed
+func (cli *CLI) fundBenchAccounts(ctx context.Context, client *newtonclient.Client, from common.Address, benchAccounts []*benchAccount, amount *big.Int, gasLimit uint64) error {
+	info, err := client.GetBaseInfo(ctx, from)
+	if err != nil {
+		return err
+	}
+	ns, err := openNonceStore(nonceFile())
+	if err != nil {
+		return err
+	}
+	defer ns.Close()
+	nonce := info.NoncePending
+	if next, ok := ns.next(info.NetworkID, from); ok && next > nonce {
+		nonce = next
+	}
+
+	wallet := keystore.NewKeyStore(cli.walletPath, keystore.LightScryptN, keystore.LightScryptP)
+	account := accounts.Account{Address: from}
+	walletPassword, err := getPassPhrase(fmt.Sprintf("Unlocking account %s to fund %d test accounts", from.String(), len(benchAccounts)), false)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Unlock(account, walletPassword); err != nil {
+		return err
+	}
+	defer wallet.Lock(from)
+
+	signer := types.NewEIP155Signer(new(big.Int).SetUint64(info.NetworkID))
+	txs := make([]*types.Transaction, 0, len(benchAccounts))
+	for _, a := range benchAccounts {
+		tx := types.NewTransaction(nonce, a.address, amount, gasLimit, info.GasPrice, nil)
+		hash := signer.Hash(tx).Bytes()
+		sign, err := wallet.SignHash(account, hash)
+		if err != nil {
+			return err
+		}
+		signature, err := utils.RecoverSignature(hash, sign, from)
+		if err != nil {
+			return err
+		}
+		signedTx, err := tx.WithSignature(signer, signature)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, signedTx)
+		nonce++
+	}
+
+	for start := 0; start < len(txs); start += payBatchSize {
+		end := start + payBatchSize
+		if end > len(txs) {
+			end = len(txs)
+		}
+		results, err := client.SendRawTransactions(ctx, txs[start:end], 2)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result.Error != nil {
+				return fmt.Errorf("fund %s error: %v", benchAccounts[start+i].address.String(), result.Error)
+			}
+		}
+		if err := ns.advance(info.NetworkID, from, txs[end-1].Nonce()); err != nil {
+			fmt.Fprintln(os.Stderr, "Update nonce store:", err)
+		}
+		fmt.Fprintf(os.Stderr, "Funded %d/%d\n", end, len(txs))
+	}
+
+	return nil
+}
+
+// signBenchRun pre-signs the transfers of each account back to the funder from the pending nonce
+func signBenchRun(ctx context.Context, client *newtonclient.Client, benchAccounts []*benchAccount, to common.Address, txs int, amount *big.Int, gasLimit uint64) error {
+	addresses := make([]common.Address, len(benchAccounts))
+	for i, a := range benchAccounts {
+		addresses[i] = a.address
+	}
+	infos, err := client.GetBaseInfos(ctx, addresses)
+	if err != nil {
+		return err
+	}
+
+	for i, a := range benchAccounts {
+		if infos[i].Error != nil {
+			return infos[i].Error
+		}
+		signer := types.NewEIP155Signer(new(big.Int).SetUint64(infos[i].NetworkID))
+		nonce := infos[i].NoncePending
+		a.txs = make([]*benchTx, 0, txs)
+		for j := 0; j < txs; j++ {
+			tx := types.NewTransaction(nonce, to, amount, gasLimit, infos[i].GasPrice, nil)
+			btx, err := signBenchTx(a.key, tx, signer)
+			if err != nil {
+				return err
+			}
+			a.txs = append(a.txs, btx)
+			nonce++
+		}
+	}
+
+	return nil
+}
+
+// runBench sends the pre-signed txs, each worker sends the txs of an account in nonce order
+func runBench(ctx context.Context, client *newtonclient.Client, tracker *benchTracker, benchAccounts []*benchAccount, method string, wait uint64, concurrency int) time.Duration {
+	queue := make(chan *benchAccount, len(benchAccounts))
+	for _, a := range benchAccounts {
+		queue <- a
+	}
+	close(queue)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range queue {
+				for _, btx := range a.txs {
+					sent := tracker.start(btx.tx.Hash())
+					if method == benchMethodRaw {
+						_, btx.err = client.SendRawTransaction(ctx, btx.tx, wait)
+					} else {
+						_, btx.err = client.SendTransaction(ctx, btx.rlpTx, btx.signature, a.address, wait)
+					}
+					btx.response = time.Since(sent)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return time.Since(start)
+}
+
+// waitBenchSettled waits the pending txs of the accounts to be mined, so the next run signs from the settled nonces
+func waitBenchSettled(ctx context.Context, client *newtonclient.Client, benchAccounts []*benchAccount, timeout time.Duration) error {
+	addresses := make([]common.Address, len(benchAccounts))
+	for i, a := range benchAccounts {
+		addresses[i] = a.address
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		infos, err := client.GetBaseInfos(ctx, addresses)
+		if err != nil {
+			return err
+		}
+		settled := true
+		for _, info := range infos {
+			if info.Error != nil || info.NonceLatest < info.NoncePending {
+				settled = false
+				break
+			}
+		}
+		if settled {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("timeout waiting for the txs to be mined")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// refundBenchAccounts waits the pending txs of the accounts to be mined,
+// then sends the remaining balance back to the funder and waits to be confirmed
+func refundBenchAccounts(ctx context.Context, client *newtonclient.Client, benchAccounts []*benchAccount, to common.Address, gasLimit uint64, timeout time.Duration) error {
+	if err := waitBenchSettled(ctx, client, benchAccounts, timeout); err != nil {
+		return err
+	}
+
+	addresses := make([]common.Address, len(benchAccounts))
+	for i, a := range benchAccounts {
+		addresses[i] = a.address
+	}
+	infos, err := client.GetBaseInfos(ctx, addresses)
+	if err != nil {
+		return err
+	}
+
+	var txs []*types.Transaction
+	for i, a := range benchAccounts {
+		info := infos[i]
+		if info.Error != nil {
+			return info.Error
+		}
+		value := new(big.Int).Sub(info.Balance, new(big.Int).Mul(info.GasPrice, new(big.Int).SetUint64(gasLimit)))
+		if value.Sign() <= 0 {
+			continue
+		}
+		signer := types.NewEIP155Signer(new(big.Int).SetUint64(info.NetworkID))
+		btx, err := signBenchTx(a.key, types.NewTransaction(info.NonceLatest, to, value, gasLimit, info.GasPrice, nil), signer)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, btx.tx)
+	}
+
+	failed := 0
+	for start := 0; start < len(txs); start += payBatchSize {
+		end := start + payBatchSize
+		if end > len(txs) {
+			end = len(txs)
+		}
+		results, err := client.SendRawTransactions(ctx, txs[start:end], 2)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Error != nil {
+				fmt.Fprintln(os.Stderr, "Refund error:", result.Error)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d refunds failed", failed, len(txs))
+	}
+
+	return nil
+}
+
+// refundBenchKeys refunds the test accounts and removes the keys file after all are refunded
+func refundBenchKeys(ctx context.Context, client *newtonclient.Client, path string, benchAccounts []*benchAccount, to common.Address, gasLimit uint64, timeout time.Duration) error {
+	if err := refundBenchAccounts(ctx, client, benchAccounts, to, gasLimit, timeout); err != nil {
+		return fmt.Errorf("%v, the keys are kept in %s, run bench refund later", err, path)
+	}
+	return os.Remove(path)
+}
+
+// benchRunOnce signs and sends the txs of the method at the wait level, then waits them to be settled
+func benchRunOnce(ctx context.Context, client *newtonclient.Client, tracker *benchTracker, notified bool, benchAccounts []*benchAccount, to common.Address,
+	result *benchResult, method string, wait uint64, txs, concurrency int, amount *big.Int, gasLimit uint64, timeout time.Duration) error {
+	if err := signBenchRun(ctx, client, benchAccounts, to, txs, amount, gasLimit); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Run %s wait %d\n", benchMethodNames[method], wait)
+	duration := runBench(ctx, client, tracker, benchAccounts, method, wait, concurrency)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var hashes []common.Hash
+	for _, a := range benchAccounts {
+		for _, btx := range a.txs {
+			if btx.err == nil {
+				hashes = append(hashes, btx.tx.Hash())
+			}
+		}
+	}
+	if notified && !tracker.waitConfirmed(ctx, hashes, timeout) {
+		fmt.Fprintln(os.Stderr, "Timeout waiting for the confirmed notifications")
+	}
+	if err := waitBenchSettled(ctx, client, benchAccounts, timeout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	run, benchErrors := benchRunResult(tracker, benchAccounts, method, wait, duration)
+	result.Runs = append(result.Runs, run)
+	result.Errors = append(result.Errors, benchErrors...)
+
+	return ctx.Err()
+}
+
+// benchRunResult collects the results of the txs of the run
+func benchRunResult(tracker *benchTracker, benchAccounts []*benchAccount, method string, wait uint64, duration time.Duration) (benchRun, []benchError) {
+	run := benchRun{
+		Method:   benchMethodNames[method],
+		Wait:     wait,
+		Duration: duration.Round(time.Millisecond).String(),
+	}
+
+	var responses []time.Duration
+	var hashes []common.Hash
+	errorCounts := make(map[string]int)
+	var errorOrder []string
+	for _, a := range benchAccounts {
+		for _, btx := range a.txs {
+			run.Sent++
+			if btx.err != nil {
+				run.Failed++
+				if errorCounts[btx.err.Error()] == 0 {
+					errorOrder = append(errorOrder, btx.err.Error())
+				}
+				errorCounts[btx.err.Error()]++
+				continue
+			}
+			responses = append(responses, btx.response)
+			hashes = append(hashes, btx.tx.Hash())
+		}
+	}
+
+	if duration > 0 {
+		run.TPS = math.Round(float64(len(responses))/duration.Seconds()*10) / 10
+	}
+	run.Response = newLatency(responses)
+	broadcast, confirmed := tracker.latencies(hashes)
+	run.Broadcast, run.Confirmed = newLatency(broadcast), newLatency(confirmed)
+
+	benchErrors := make([]benchError, 0, len(errorOrder))
+	for _, e := range errorOrder {
+		benchErrors = append(benchErrors, benchError{Method: run.Method, Wait: wait, Count: errorCounts[e], Error: e})
+	}
+
+	return run, benchErrors
+}
+
+func (cli *CLI) buildBenchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "bench <--from address> [-n accounts] [--txs count] [--concurrency n] [--method raw,tx] [--wait 0,1,2] [--amount amount] [--timeout duration]",
+		Short:                 "Benchmark the latency of the express server by the transfers of the generated test accounts",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fromStr, _ := cmd.Flags().GetString("from")
+			from, err := parseAddress(fromStr)
+			if err != nil {
+				cli.fail(fmt.Errorf("from address error: %v", err))
+				return
+			}
+
+			numAccounts, _ := cmd.Flags().GetInt("accounts")
+			txs, _ := cmd.Flags().GetInt("txs")
+			concurrency, _ := cmd.Flags().GetInt("concurrency")
+			if numAccounts < 1 || numAccounts > maxBenchAccounts || txs < 1 || concurrency < 1 {
+				cli.fail(fmt.Errorf("accounts must be 1 to %d, txs and concurrency must be positive", maxBenchAccounts))
+				return
+			}
+			if concurrency > numAccounts {
+				concurrency = numAccounts
+			}
+
+			methods, _ := cmd.Flags().GetStringSlice("method")
+			for _, method := range methods {
+				if _, ok := benchMethodNames[method]; !ok {
+					cli.fail(fmt.Errorf("invalid method %s, raw or tx", method))
+					return
+				}
+			}
+			waits, _ := cmd.Flags().GetUintSlice("wait")
+			for _, wait := range waits {
+				if wait > 2 {
+					cli.fail(fmt.Errorf("invalid wait level %d, 0, 1 or 2", wait))
+					return
+				}
+			}
+
+			amountStr, _ := cmd.Flags().GetString("amount")
+			amount, err := getAmountWei(amountStr, UnitETH)
+			if err != nil {
+				cli.fail(fmt.Errorf("amount error: %v", err))
+				return
+			}
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
+			}
+			client, err := newtonclient.Dial(rpcurl)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			// the notifications of the transfers back to the funder, the latencies are not reported without [Notify]
+			tracker := newBenchTracker()
+			notify, err := loadNotifyConfig("")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Notify config: %v, the broadcast and confirmed latencies are skipped\n", err)
+			} else {
+				topic := notification.AddressTopic(notify.PrefixTopic, from, "")
+				nc, err := subscribeNotifications(notify, topic, tracker.observe)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				defer nc.Disconnect(250)
+			}
+
+			info, err := client.GetBaseInfo(ctx, from)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			gasLimit := uint64(viper.GetInt64("Client.GasLimit"))
+			if gasLimit == 0 {
+				gasLimit = 21000
+			}
+
+			// each account pays the transfers of all the runs and the refund
+			runs := len(methods) * len(waits)
+			fee := new(big.Int).Mul(info.GasPrice, new(big.Int).SetUint64(gasLimit))
+			perTx := new(big.Int).Add(amount, fee)
+			funding := new(big.Int).Mul(perTx, big.NewInt(int64(runs*txs)))
+			funding.Add(funding, fee)
+
+			benchAccounts := make([]*benchAccount, numAccounts)
+			for i := range benchAccounts {
+				key, err := crypto.GenerateKey()
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				benchAccounts[i] = &benchAccount{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+			}
+			keysFile, _ := cmd.Flags().GetString("keys")
+			if err := saveBenchKeys(keysFile, from, benchAccounts); err != nil {
+				cli.fail(err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Save the keys of the test accounts to %s, it is removed after refunded\n", keysFile)
+
+			// the runs stop at the first interrupt and the accounts are refunded, the next interrupt exits
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				select {
+				case <-sigs:
+					fmt.Fprintln(os.Stderr, "Interrupted, refund the test accounts")
+					cancel()
+				case <-runCtx.Done():
+				}
+				signal.Stop(sigs)
+			}()
+
+			total := new(big.Int).Mul(funding, big.NewInt(int64(numAccounts)))
+			fmt.Fprintf(os.Stderr, "Fund %d test accounts with %s %s each, %s %s in total\n", numAccounts,
+				getWeiAmountTextByUnit(funding, UnitETH), UnitETH, getWeiAmountTextByUnit(total, UnitETH), UnitETH)
+			result := &benchResult{
+				Accounts:    numAccounts,
+				TxsPerRun:   numAccounts * txs,
+				Concurrency: concurrency,
+				Runs:        make([]benchRun, 0, runs),
+			}
+			err = cli.fundBenchAccounts(runCtx, client, from, benchAccounts, funding, gasLimit)
+			for _, method := range methods {
+				for _, wait := range waits {
+					if err != nil {
+						break
+					}
+					err = benchRunOnce(runCtx, client, tracker, notify != nil, benchAccounts, from, result, method, uint64(wait), txs, concurrency, amount, gasLimit, timeout)
+				}
+			}
+			cancel()
+
+			// refund on every exit of the runs, the funding may be partially confirmed
+			if rerr := refundBenchKeys(ctx, client, keysFile, benchAccounts, from, gasLimit, timeout); rerr != nil {
+				fmt.Fprintln(os.Stderr, "Refund error:", rerr)
+			}
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(result)
+		},
+	}
+
+	refundCmd := &cobra.Command{
+		Use:                   "refund [--keys path] [--timeout duration]",
+		Short:                 "Refund the test accounts in the keys file of an interrupted bench to the funder",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			keysFile, _ := cmd.Flags().GetString("keys")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			from, benchAccounts, err := loadBenchKeys(keysFile)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
+			}
+			client, err := newtonclient.Dial(rpcurl)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer client.Close()
+
+			gasLimit := uint64(viper.GetInt64("Client.GasLimit"))
+			if gasLimit == 0 {
+				gasLimit = 21000
+			}
+			if err := refundBenchKeys(context.Background(), client, keysFile, benchAccounts, from, gasLimit, timeout); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(&benchRefundResult{Funder: from, Accounts: len(benchAccounts)})
+		},
+	}
+	refundCmd.Flags().String("keys", defaultBenchKeysFile, "the `path` of the keys file of the test accounts")
+	refundCmd.Flags().Duration("timeout", 2*time.Minute, "the max wait of the pending txs of the test accounts to be mined")
+	cmd.AddCommand(refundCmd)
+
+	cmd.Flags().String("from", "", "the funder `address` in the keystore, the test accounts are funded by it and pay back to it")
+	cmd.Flags().IntP("accounts", "n", 10, "the `number` of the generated test accounts")
+	cmd.Flags().Int("txs", 10, "the `count` of the transfers of each account in each run")
+	cmd.Flags().Int("concurrency", 10, "the `number` of the accounts sending at the same time, the txs of an account are sent in order")
+	cmd.Flags().StringSlice("method", []string{benchMethodRaw, benchMethodTx}, "the `methods`, raw for newton_sendRawTransaction, tx for newton_sendTransaction")
+	cmd.Flags().UintSlice("wait", []uint{0, 1, 2}, "the wait `levels` of the runs")
+	cmd.Flags().String("amount", "0.000001", "the `amount` in NEW of each transfer")
+	cmd.Flags().Duration("timeout", 2*time.Minute, "the max wait of the txs of each run to be confirmed")
+	cmd.Flags().String("keys", defaultBenchKeysFile, "the `path` to save the keys of the test accounts before funding, removed after refunded")
+
+	return cmd
+}
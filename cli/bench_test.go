@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+func TestLatency(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	l := newLatency(durations)
+	if l.Count != 100 || l.P50 != 50 || l.P90 != 90 || l.P99 != 99 || l.Max != 100 {
+		t.Errorf("latency mismatch: %+v", l)
+	}
+	if l.String() != "50/90/99/100ms" {
+		t.Errorf("latency text mismatch: %s", l.String())
+	}
+	if newLatency(nil).String() != "-" {
+		t.Errorf("empty latency text mismatch: %s", newLatency(nil).String())
+	}
+}
+
+func TestSignBenchTx(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.NewEIP155Signer(big.NewInt(1007))
+
+	for nonce := uint64(0); nonce < 10; nonce++ {
+		tx := types.NewTransaction(nonce, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(100), nil)
+		btx, err := signBenchTx(key, tx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the raw tx is recovered to the sender
+		sender, err := types.Sender(signer, btx.tx)
+		if err != nil || sender != from {
+			t.Fatalf("nonce %d: sender %s mismatch %s, %v", nonce, sender.String(), from.String(), err)
+		}
+		// the [R || S] of newton_sendTransaction is recovered to the sender as the server does
+		if _, err := utils.RecoverSignature(signer.Hash(tx).Bytes(), btx.signature, from); err != nil {
+			t.Fatalf("nonce %d: %v", nonce, err)
+		}
+	}
+}
+
+func TestBenchKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	funder := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	benchAccounts := make([]*benchAccount, 3)
+	for i := range benchAccounts {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		benchAccounts[i] = &benchAccount{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+
+	path := filepath.Join(dir, "bench-keys.json")
+	if err := saveBenchKeys(path, funder, benchAccounts); err != nil {
+		t.Fatal(err)
+	}
+	// the keys not refunded are never overwritten
+	if err := saveBenchKeys(path, funder, benchAccounts[:1]); err == nil {
+		t.Error("existing keys file overwritten")
+	}
+
+	loadedFunder, loaded, err := loadBenchKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedFunder != funder || len(loaded) != len(benchAccounts) {
+		t.Fatalf("loaded funder %s with %d accounts, want %s with %d", loadedFunder.String(), len(loaded), funder.String(), len(benchAccounts))
+	}
+	for i, a := range loaded {
+		if a.address != benchAccounts[i].address {
+			t.Errorf("account %d: %s mismatch %s", i, a.address.String(), benchAccounts[i].address.String())
+		}
+	}
+}
@@ -26,6 +26,8 @@ type CLI struct {
 	testing    bool
 	logfile    string
 
+	outputFormat string // table, json or yaml
+
 	blockchain BlockChain
 }
 
@@ -81,16 +83,20 @@ func (cli *CLI) Execute() {
 // setup turns up the CLI environment, and gets called by Cobra before
 // a command is executed.
 func (cli *CLI) setup(cmd *cobra.Command, args []string) {
+	if !stringInSlice(cli.outputFormat, outputFormats) {
+		fmt.Fprintf(os.Stderr, "Invalid output format %s, only %s\n", cli.outputFormat, strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
 	if err := cli.setupConfig(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
 func (cli *CLI) help(cmd *cobra.Command, args []string) {
 	fmt.Fprint(os.Stderr, cmd.UsageString())
 
-	os.Exit(-1)
+	os.Exit(1)
 
 }
 
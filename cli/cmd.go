@@ -22,16 +22,26 @@ func (cli *CLI) buildRootCmd() {
 	rootCmd.PersistentFlags().StringVarP(&cli.config, "config", "c", defaultConfigFile, "the `path` to config file")
 	rootCmd.PersistentFlags().StringP("rpcURL", "i", defaultRPCURL, "NewChain json rpc or ipc `url`")
 	rootCmd.PersistentFlags().StringP("host", "H", "127.0.0.1:8888", "the `host` of the server, [bind_address]:port")
+	rootCmd.PersistentFlags().StringVar(&cli.outputFormat, "output", outputTable, "the output `format`, table, json or yaml")
 
 	// Basic commands
 	rootCmd.AddCommand(cli.buildVersionCmd()) // version
 
 	// server
 	rootCmd.AddCommand(cli.buildServerCmd()) // NewChainAPIExpress server
+	rootCmd.AddCommand(cli.buildAPIKeyCmd()) // apikey
+	rootCmd.AddCommand(cli.buildWatchCmd())  // watch
 
 	// client
-	rootCmd.AddCommand(cli.buildAccountCmd()) // account
-	rootCmd.AddCommand(cli.buildPayCmd())     // pay
-	rootCmd.AddCommand(cli.buildInfoCmd())    // info
+	rootCmd.AddCommand(cli.buildAccountCmd())       // account
+	rootCmd.AddCommand(cli.buildPayCmd())           // pay
+	rootCmd.AddCommand(cli.buildInfoCmd())          // info
+	rootCmd.AddCommand(cli.buildHistoryCmd())       // history
+	rootCmd.AddCommand(cli.buildTxCmd())            // tx
+	rootCmd.AddCommand(cli.buildNonceCmd())         // nonce
+	rootCmd.AddCommand(cli.buildContractCmd())      // contract
+	rootCmd.AddCommand(cli.buildSignMessageCmd())   // sign-message
+	rootCmd.AddCommand(cli.buildVerifyMessageCmd()) // verify-message
+	rootCmd.AddCommand(cli.buildBenchCmd())         // bench
 
 }
@@ -2,12 +2,22 @@ package cli
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 const defaultConfigFile = "./config.toml"
 const defaultWalletPath = "./wallet"
+const defaultDataDir = "./data"
+const defaultAPIKeysFile = "./apikeys.json"
+const defaultWSHost = "127.0.0.1:8889"
+const metricsPath = "/metrics"
+const healthzPath = "/healthz"
+const readyzPath = "/readyz"
+
+// the wait level 2 requests are waiting for the tx to be mined
+const defaultWriteTimeout = 120 * time.Second
 
 func (cli *CLI) defaultConfig() {
 
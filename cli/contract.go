@@ -0,0 +1,527 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func (cli *CLI) buildContractCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "contract",
+		Short: "Deploy, call or send to the contract through API",
+		Long: "Deploy, call or send to the contract through API, the arguments are ABI-encoded " +
+			"(arrays as JSON, e.g. [1,2]), the proxy of the server must allow eth_call and eth_estimateGas",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(cli.buildContractDeployCmd()) // contract deploy
+	cmd.AddCommand(cli.buildContractCallCmd())   // contract call
+	cmd.AddCommand(cli.buildContractSendCmd())   // contract send
+
+	return cmd
+}
+
+// contractDeployResult is the deployed contract and the tx creating it
+type contractDeployResult struct {
+	Contract common.Address `json:"contract"`
+	From     common.Address `json:"from"`
+	Nonce    uint64         `json:"nonce"`
+	Hash     common.Hash    `json:"hash"`
+}
+
+func (cli *CLI) buildContractDeployCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "deploy <bytecode> [args...] <--from address> [--abi abi.json] [--value amount] [--gas limit] [--wait level]",
+		Short:                 "Deploy the contract of the hex bytecode or bytecode file, the constructor args need --abi",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			bytecode, err := readBytecode(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("bytecode error: %v", err))
+				return
+			}
+
+			data := bytecode
+			abiPath, _ := cmd.Flags().GetString("abi")
+			if abiPath != "" {
+				contractABI, err := loadABI(abiPath)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				params, err := parseABIArgs(contractABI.Constructor.Inputs, args[1:])
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				input, err := contractABI.Pack("", params...)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				data = append(data, input...)
+			} else if len(args) > 1 {
+				cli.fail(errors.New("the constructor args need --abi"))
+				return
+			}
+
+			otx, hash, err := cli.sendContractTx(cmd, nil, data)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(&contractDeployResult{
+				Contract: crypto.CreateAddress(otx.From, uint64(otx.Nonce)),
+				From:     otx.From,
+				Nonce:    uint64(otx.Nonce),
+				Hash:     hash,
+			})
+		},
+	}
+
+	cmd.Flags().String("abi", "", "the `path` of the ABI JSON file for the constructor args")
+	addContractTxFlags(cmd)
+
+	return cmd
+}
+
+// contractOutput is the unpacked output of the method, the name is the index if unnamed
+type contractOutput struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type contractCallResult struct {
+	Outputs []contractOutput `json:"outputs"`
+}
+
+func (cli *CLI) buildContractCallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "call <address> <abi.json> <method> [args...] [--from address] [--block number]",
+		Short:                 "Call the read-only method of the contract by eth_call",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			to, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("contract address error: %v", err))
+				return
+			}
+
+			contractABI, err := loadABI(args[1])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			method, data, err := packMethod(contractABI, args[2], args[3:])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			msg := ethereum.CallMsg{To: &to, Data: data}
+			if fromStr, _ := cmd.Flags().GetString("from"); fromStr != "" {
+				msg.From, err = parseAddress(fromStr)
+				if err != nil {
+					cli.fail(fmt.Errorf("from address error: %v", err))
+					return
+				}
+			}
+			var blockNumber *big.Int
+			if cmd.Flags().Changed("block") {
+				number, _ := cmd.Flags().GetUint64("block")
+				blockNumber = new(big.Int).SetUint64(number)
+			}
+
+			client, err := newtonclient.Dial(cli.clientRPCURL())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer client.Close()
+
+			output, err := client.CallContract(context.Background(), msg, blockNumber)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			result := &contractCallResult{Outputs: []contractOutput{}}
+			if len(method.Outputs) == 0 {
+				cli.output(result)
+				return
+			}
+			if len(output) == 0 {
+				cli.fail(errors.New("no output, the address may not be a contract"))
+				return
+			}
+
+			values, err := method.Outputs.UnpackValues(output)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			for i, v := range values {
+				name := method.Outputs[i].Name
+				if name == "" {
+					name = strconv.Itoa(i)
+				}
+				result.Outputs = append(result.Outputs, contractOutput{
+					Name:  name,
+					Type:  method.Outputs[i].Type.String(),
+					Value: formatABIValue(v),
+				})
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().String("from", "", "the from address of the call")
+	cmd.Flags().Uint64("block", 0, "the block `number` of the call, default is latest")
+
+	return cmd
+}
+
+func (cli *CLI) buildContractSendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "send <address> <abi.json> <method> [args...] <--from address> [--value amount] [--gas limit] [--wait level]",
+		Short:                 "Send the tx invoking the method of the contract",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			to, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("contract address error: %v", err))
+				return
+			}
+
+			contractABI, err := loadABI(args[1])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			method, data, err := packMethod(contractABI, args[2], args[3:])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			if method.Const {
+				cli.fail(fmt.Errorf("the method %s is constant, use contract call instead", method.Name))
+				return
+			}
+
+			otx, hash, err := cli.sendContractTx(cmd, &to, data)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(&submitResult{From: otx.From, Nonce: uint64(otx.Nonce), Hash: hash})
+		},
+	}
+
+	addContractTxFlags(cmd)
+
+	return cmd
+}
+
+func addContractTxFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "the from address")
+	cmd.Flags().String("value", "0", "the `amount` of NEW sent to the contract")
+	cmd.Flags().Uint64("gas", 0, "the gas `limit`, default is estimated by API")
+	cmd.Flags().Uint64("wait", 1, "the wait level(0,1,2)")
+}
+
+func (cli *CLI) clientRPCURL() string {
+	rpcurl := viper.GetString("Client.RPCUrl")
+	if rpcurl == "" {
+		rpcurl = cli.rpcURL
+	}
+	return rpcurl
+}
+
+// sendContractTx estimates the gas if not set, signs with the keystore and submits the tx,
+// the nonce is taken from and advanced in the nonce store
+func (cli *CLI) sendContractTx(cmd *cobra.Command, to *common.Address, data []byte) (*offlineTx, common.Hash, error) {
+	fromStr, _ := cmd.Flags().GetString("from")
+	from, err := parseAddress(fromStr)
+	if err != nil {
+		return nil, common.Hash{}, fmt.Errorf("from address error: %v", err)
+	}
+
+	valueStr, _ := cmd.Flags().GetString("value")
+	value, err := getAmountWei(valueStr, UnitETH)
+	if err != nil {
+		return nil, common.Hash{}, fmt.Errorf("value error: %v", err)
+	}
+	gas, _ := cmd.Flags().GetUint64("gas")
+	wait, _ := cmd.Flags().GetUint64("wait")
+
+	rpcurl := cli.clientRPCURL()
+	client, err := newtonclient.Dial(rpcurl)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if gas == 0 {
+		gas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: to, Value: value, Data: data})
+		if err != nil {
+			return nil, common.Hash{}, fmt.Errorf("estimate gas error: %v", err)
+		}
+	}
+
+	// hold the nonce store until submitted, so the concurrent txs never use the same nonce
+	ns, err := openNonceStore(nonceFile())
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	defer ns.Close()
+	nonce, err := ns.pendingNonce(ctx, rpcurl, viper.GetUint64("Client.ChainID"), from)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+
+	otx, err := newOfflineTx(from, to, value, data, gas, nonce)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	otx.show()
+
+	if err := cli.signOfflineTx(otx); err != nil {
+		return nil, common.Hash{}, err
+	}
+
+	hash, err := submitOfflineTx(otx, wait, false)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+
+	advanceNonce(ns, otx)
+
+	return otx, hash, nil
+}
+
+// readBytecode decodes the hex bytecode, or reads it from the file if exists
+func readBytecode(s string) ([]byte, error) {
+	if _, err := os.Stat(s); err == nil {
+		data, err := ioutil.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(string(data))
+	}
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "0x" + s
+	}
+
+	bytecode, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytecode) == 0 {
+		return nil, errors.New("empty bytecode")
+	}
+
+	return bytecode, nil
+}
+
+func loadABI(path string) (abi.ABI, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	defer f.Close()
+
+	return abi.JSON(f)
+}
+
+// packMethod returns the method and its call data with the args
+func packMethod(contractABI abi.ABI, name string, args []string) (*abi.Method, []byte, error) {
+	method, ok := contractABI.Methods[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("method %s not found in ABI", name)
+	}
+	params, err := parseABIArgs(method.Inputs, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := contractABI.Pack(name, params...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &method, data, nil
+}
+
+// parseABIArgs converts the command line args to the Go values of the ABI arguments
+func parseABIArgs(inputs abi.Arguments, args []string) ([]interface{}, error) {
+	if len(args) != len(inputs) {
+		return nil, fmt.Errorf("want %d args, got %d", len(inputs), len(args))
+	}
+
+	params := make([]interface{}, len(args))
+	for i, input := range inputs {
+		v, err := parseABIValue(input.Type, args[i])
+		if err != nil {
+			return nil, fmt.Errorf("arg %d (%s %s): %v", i, input.Type.String(), input.Name, err)
+		}
+		params[i] = v.Interface()
+	}
+
+	return params, nil
+}
+
+func parseABIValue(t abi.Type, s string) (reflect.Value, error) {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return reflect.Value{}, errors.New("invalid integer")
+		}
+		if t.T == abi.UintTy && n.Sign() < 0 {
+			return reflect.Value{}, errors.New("negative unsigned integer")
+		}
+		bits, m := t.Size, n
+		if t.T == abi.IntTy {
+			// the sign bit, and -n-1 of the negative has the same bit length as the two's complement
+			bits--
+			if n.Sign() < 0 {
+				m = new(big.Int).Not(n)
+			}
+		}
+		if m.BitLen() > bits {
+			return reflect.Value{}, errors.New("integer overflow")
+		}
+		if t.Type == reflect.TypeOf(n) {
+			return reflect.ValueOf(n), nil
+		}
+		v := reflect.New(t.Type).Elem()
+		if t.T == abi.IntTy {
+			v.SetInt(n.Int64())
+		} else {
+			v.SetUint(n.Uint64())
+		}
+		return v, nil
+
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.StringTy:
+		return reflect.ValueOf(s), nil
+
+	case abi.AddressTy:
+		address, err := parseAddress(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(address), nil
+
+	case abi.BytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(b) > t.Size {
+			return reflect.Value{}, fmt.Errorf("want at most %d bytes", t.Size)
+		}
+		v := reflect.New(t.Type).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+
+	case abi.SliceTy, abi.ArrayTy:
+		var elems []json.RawMessage
+		if err := json.Unmarshal([]byte(s), &elems); err != nil {
+			return reflect.Value{}, fmt.Errorf("want JSON array: %v", err)
+		}
+		var v reflect.Value
+		if t.T == abi.SliceTy {
+			v = reflect.MakeSlice(t.Type, len(elems), len(elems))
+		} else {
+			if len(elems) != t.Size {
+				return reflect.Value{}, fmt.Errorf("want %d elements, got %d", t.Size, len(elems))
+			}
+			v = reflect.New(t.Type).Elem()
+		}
+		for i, raw := range elems {
+			elem := string(raw)
+			var str string
+			if err := json.Unmarshal(raw, &str); err == nil {
+				elem = str
+			}
+			ev, err := parseABIValue(*t.Elem, elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	}
+
+	return reflect.Value{}, errors.New("unsupported type")
+}
+
+// formatABIValue formats the unpacked value, bytes in hex
+func formatABIValue(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return hexutil.Encode(v)
+	case common.Address:
+		return v.String()
+	case common.Hash:
+		return v.String()
+	case *big.Int:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = formatABIValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	}
+
+	return fmt.Sprint(v)
+}
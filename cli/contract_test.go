@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const testABI = `[
+	{"type":"constructor","inputs":[{"name":"supply","type":"uint256"}]},
+	{"type":"function","name":"transfer","constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"set","constant":false,"inputs":[{"name":"a","type":"int8"},{"name":"b","type":"bytes4"},{"name":"c","type":"uint16[]"}],"outputs":[]}
+]`
+
+func TestPackMethod(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, data, err := packMethod(contractABI, "transfer", []string{"0x97549e368acafdcae786bb93d98379f1d1561a29", "1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0xa9059cbb" +
+		"00000000000000000000000097549e368acafdcae786bb93d98379f1d1561a29" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	if hexutil.Encode(data) != want {
+		t.Errorf("data = %s, want %s", hexutil.Encode(data), want)
+	}
+
+	if _, _, err := packMethod(contractABI, "set", []string{"-128", "0x01020304", `[1,"0x2"]`}); err != nil {
+		t.Error(err)
+	}
+
+	for _, args := range [][]string{
+		{"128", "0x01020304", "[]"},    // int8 overflow
+		{"1", "0x0102030405", "[]"},    // too many bytes
+		{"1", "0x01020304", "[65536]"}, // uint16 overflow
+		{"1", "0x01020304", "1,2"},     // not JSON array
+		{"1", "0x01020304"},            // missing arg
+	} {
+		if _, _, err := packMethod(contractABI, "set", args); err == nil {
+			t.Errorf("args %v accepted", args)
+		}
+	}
+}
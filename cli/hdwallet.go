@@ -0,0 +1,363 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/console"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/hdwallet"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultMnemonicFile = "./mnemonic.json"
+
+// mnemonicJSON is the mnemonic encrypted as the keystore
+type mnemonicJSON struct {
+	Version int                 `json:"version"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+}
+
+func mnemonicFile() string {
+	viper.SetDefault("Client.MnemonicFile", defaultMnemonicFile)
+	return viper.GetString("Client.MnemonicFile")
+}
+
+// saveMnemonic encrypts the mnemonic with the password, the existing mnemonic file is never overwritten
+func saveMnemonic(path, mnemonic, password string) error {
+	cryptoJSON, err := keystore.EncryptDataV3([]byte(mnemonic), []byte(password), keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mnemonicJSON{Version: 1, Crypto: cryptoJSON}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("mnemonic file %s exists, remove it after the backup to replace", path)
+		}
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// loadMnemonic decrypts the mnemonic file with the password
+func loadMnemonic(path, password string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("mnemonic file %s not found, run account mnemonic new or import first", path)
+		}
+		return "", err
+	}
+
+	var m mnemonicJSON
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", err
+	}
+	mnemonic, err := keystore.DecryptDataV3(m.Crypto, password)
+	if err != nil {
+		return "", err
+	}
+
+	return string(mnemonic), nil
+}
+
+// openHDWallet prompts the password and opens the HD wallet of the mnemonic file
+func openHDWallet() (*hdwallet.Wallet, error) {
+	password, err := getPassPhrase("Unlocking the mnemonic", false)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, err := loadMnemonic(mnemonicFile(), password)
+	if err != nil {
+		return nil, err
+	}
+
+	return hdwallet.NewFromMnemonic(mnemonic, "")
+}
+
+// mnemonicResult is the saved mnemonic file and the first address of the HD wallet
+type mnemonicResult struct {
+	File    string         `json:"file"`
+	Path    string         `json:"path"`
+	Address common.Address `json:"address"`
+}
+
+// storeMnemonic saves the mnemonic locked with a new password and returns the first address
+func storeMnemonic(mnemonic string) (*mnemonicResult, error) {
+	w, err := hdwallet.NewFromMnemonic(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := getPassPhrase("Your mnemonic is locked with a password. Please give a password. Do not forget this password.", true)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveMnemonic(mnemonicFile(), mnemonic, password); err != nil {
+		return nil, err
+	}
+
+	path := hdwallet.DefaultChainPath + "/0"
+	address, err := w.Address(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mnemonicResult{File: mnemonicFile(), Path: path, Address: address}, nil
+}
+
+func (cli *CLI) buildAccountMnemonicCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mnemonic [new|import]",
+		Short: "Manage the BIP-39 mnemonic of the HD wallet",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			return
+		},
+	}
+
+	newCmd := &cobra.Command{
+		Use:                   "new [--words 12]",
+		Short:                 "create a new mnemonic",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := os.Stat(mnemonicFile()); err == nil {
+				cli.fail(fmt.Errorf("mnemonic file %s exists", mnemonicFile()))
+				return
+			}
+
+			words, _ := cmd.Flags().GetInt("words")
+			mnemonic, err := hdwallet.NewMnemonic(words)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			// shown on stderr with the prompts, so the mnemonic is never piped or logged with the output
+			fmt.Fprintln(os.Stderr, "Write down the mnemonic and keep it safe, it recovers all the derived accounts:")
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(os.Stderr, mnemonic)
+			fmt.Fprintln(os.Stderr)
+
+			result, err := storeMnemonic(mnemonic)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(result)
+		},
+	}
+	newCmd.Flags().Int("words", 12, "number of the mnemonic words, 12, 15, 18, 21 or 24")
+
+	importCmd := &cobra.Command{
+		Use:                   "import",
+		Short:                 "import the existing mnemonic",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := os.Stat(mnemonicFile()); err == nil {
+				cli.fail(fmt.Errorf("mnemonic file %s exists", mnemonicFile()))
+				return
+			}
+
+			mnemonic, err := console.Stdin.PromptPassword("Enter mnemonic: ")
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			result, err := storeMnemonic(strings.Join(strings.Fields(mnemonic), " "))
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.AddCommand(newCmd, importCmd)
+
+	return cmd
+}
+
+// derivedAccount is the account at the path, Status is exists or imported if --import
+type derivedAccount struct {
+	Path    string         `json:"path"`
+	Address common.Address `json:"address"`
+	Status  string         `json:"status,omitempty"`
+}
+
+type deriveResult struct {
+	Accounts []derivedAccount `json:"accounts"`
+}
+
+func (cli *CLI) buildAccountDeriveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "derive [--index 0] [-n 1] [--path m/44'/1642'/0'/0] [--xpub key] [--import]",
+		Short:                 "derive the accounts of the HD wallet at <path>/<index>",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			index, _ := cmd.Flags().GetUint32("index")
+			count, _ := cmd.Flags().GetUint32("numOfDerive")
+			path, _ := cmd.Flags().GetString("path")
+			xpub, _ := cmd.Flags().GetString("xpub")
+			importKeys, _ := cmd.Flags().GetBool("import")
+			result := &deriveResult{Accounts: []derivedAccount{}}
+			if count == 0 || uint64(index)+uint64(count) > hdwallet.HardenedKeyStart {
+				cli.fail(errors.New("index out of range"))
+				return
+			}
+
+			// the watch-only addresses without the mnemonic
+			if xpub != "" {
+				if importKeys {
+					cli.fail(errors.New("cannot import the keys of xpub"))
+					return
+				}
+				for i := index; i < index+count; i++ {
+					relative := fmt.Sprintf("0/%d", i)
+					address, err := hdwallet.DeriveAddress(xpub, relative)
+					if err != nil {
+						cli.fail(err)
+						return
+					}
+					result.Accounts = append(result.Accounts, derivedAccount{Path: "<xpub>/" + relative, Address: address})
+				}
+				cli.output(result)
+				return
+			}
+
+			w, err := openHDWallet()
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			var wallet *keystore.KeyStore
+			var walletPassword string
+			if importKeys {
+				wallet = keystore.NewKeyStore(cli.walletPath,
+					keystore.LightScryptN, keystore.LightScryptP)
+				walletPassword, err = getPassPhrase("The imported accounts are locked with a password. Please give a password. Do not forget this password.", true)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+			}
+
+			for i := index; i < index+count; i++ {
+				keyPath := fmt.Sprintf("%s/%d", path, i)
+				key, err := w.PrivateKey(keyPath)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				account := derivedAccount{Path: keyPath, Address: crypto.PubkeyToAddress(key.PublicKey)}
+				if importKeys {
+					if wallet.HasAddress(account.Address) {
+						account.Status = "exists"
+					} else if _, err := wallet.ImportECDSA(key, walletPassword); err != nil {
+						cli.fail(err)
+						return
+					} else {
+						account.Status = "imported"
+					}
+				}
+				result.Accounts = append(result.Accounts, account)
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().Uint32("index", 0, "index of the first account")
+	cmd.Flags().Uint32P("numOfDerive", "n", 1, "number of the accounts")
+	cmd.Flags().String("path", hdwallet.DefaultChainPath, "derivation path of the accounts")
+	cmd.Flags().String("xpub", "", "derive the watch-only addresses <xpub>/0/<index> from the extended public key")
+	cmd.Flags().Bool("import", false, "import the derived keys to the keystore of the wallet path")
+	return cmd
+}
+
+// xpubResult is the extended public key of the path, the addresses <path>/0/<index> are <xpub>/0/<index>,
+// Watched is the addresses watched on the server by --watch
+type xpubResult struct {
+	XPub    string           `json:"xpub"`
+	Path    string           `json:"path"`
+	Watched []common.Address `json:"watched,omitempty"`
+}
+
+func (cli *CLI) buildAccountXPubCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "xpub [--path m/44'/1642'/0'] [--watch n]",
+		Short:                 "export the extended public key for the watch-only addresses",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			path, _ := cmd.Flags().GetString("path")
+			watch, _ := cmd.Flags().GetUint64("watch")
+
+			w, err := openHDWallet()
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			xpub, err := w.XPub(path)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			result := &xpubResult{XPub: xpub, Path: path}
+
+			if watch == 0 {
+				cli.output(result)
+				return
+			}
+			client, err := newtonclient.Dial(cli.clientRPCURL())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			addresses, err := client.WatchXPub(context.Background(), xpub, 0, watch)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			result.Watched = addresses
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().String("path", hdwallet.DefaultAccountPath, "derivation path of the account")
+	cmd.Flags().Uint64("watch", 0, "watch the first n addresses of the xpub on the server")
+	return cmd
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// historyResult is a page of the transaction history
+type historyResult struct {
+	IndexedHeight uint64      `json:"indexedHeight"`
+	NextCursor    string      `json:"nextCursor,omitempty"`
+	Transactions  []historyTx `json:"transactions"`
+}
+
+// historyTx is the transfer in the history, the value is in NEW, or the raw amount of the token
+type historyTx struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	Time        string          `json:"time"`
+	Hash        common.Hash     `json:"hash"`
+	Direction   string          `json:"direction"`
+	From        common.Address  `json:"from"`
+	To          string          `json:"to"`
+	Value       string          `json:"value"`
+	Token       *common.Address `json:"token,omitempty"`
+}
+
+func (cli *CLI) buildHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "history <address> [--cursor hex] [--limit n] [--direction in|out] [--order desc|asc]",
+		Short:                 "Get the transaction history of the address from API",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("invalid address: %v", err))
+				return
+			}
+
+			var cursor []byte
+			cursorStr, _ := cmd.Flags().GetString("cursor")
+			if cursorStr != "" {
+				var err error
+				cursor, err = hexutil.Decode(cursorStr)
+				if err != nil {
+					cli.fail(fmt.Errorf("invalid cursor: %v", err))
+					return
+				}
+			}
+			limit, _ := cmd.Flags().GetUint64("limit")
+			direction, _ := cmd.Flags().GetString("direction")
+			order, _ := cmd.Flags().GetString("order")
+
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
+			}
+
+			client, err := newtonclient.Dial(rpcurl)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			page, err := client.GetTransactions(context.Background(), address, cursor, limit, direction, order)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			result := &historyResult{
+				IndexedHeight: uint64(page.IndexedHeight),
+				Transactions:  make([]historyTx, 0, len(page.Transactions)),
+			}
+			for _, tx := range page.Transactions {
+				value := getWeiAmountTextByUnit((*big.Int)(tx.Value), UnitETH)
+				if tx.Token != nil {
+					value = tx.Value.ToInt().String()
+				}
+				result.Transactions = append(result.Transactions, historyTx{
+					BlockNumber: uint64(tx.BlockNumber),
+					Time:        time.Unix(int64(tx.Timestamp), 0).Format(time.RFC3339),
+					Hash:        tx.Hash,
+					Direction:   tx.Direction,
+					From:        tx.From,
+					To:          addressText(tx.To),
+					Value:       value,
+					Token:       tx.Token,
+				})
+			}
+			if len(page.NextCursor) > 0 {
+				result.NextCursor = hexutil.Encode(page.NextCursor)
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().String("cursor", "", "the `cursor` of the page, the NextCursor of the previous page")
+	cmd.Flags().Uint64("limit", 20, "the max `number` of the transactions in the page")
+	cmd.Flags().String("direction", "", "only show the transactions in or out")
+	cmd.Flags().String("order", "desc", "the order of the transactions, desc or asc")
+
+	return cmd
+}
@@ -3,25 +3,47 @@ package cli
 import (
 	"context"
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/newtonproject/newchain-api-express/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// infoResult is the base info of the address, the amounts are in NEW
+type infoResult struct {
+	Address      common.Address `json:"address"`
+	AddressNEW   string         `json:"addressNEW"`
+	NonceLatest  uint64         `json:"nonceLatest"`
+	NoncePending uint64         `json:"noncePending"`
+	Balance      string         `json:"balance"`
+	GasPrice     string         `json:"gasPrice"`
+	ChainID      uint64         `json:"chainID"`
+	BlockNumber  uint64         `json:"blockNumber"`
+	BlockHash    common.Hash    `json:"blockHash"`
+	ConfigFile   string         `json:"configFile,omitempty"` // the config file updated by --update
+	NonceFile    string         `json:"nonceFile,omitempty"`  // the nonce store updated by --update
+}
+
 func (cli *CLI) buildInfoCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:                   "info <hexaddress> [--update]",
+		Use:                   "info <address> [--update] [--block number]",
 		Short:                 "Get base info from API",
 		DisableFlagsInUseLine: true,
 		Args:                  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			if !common.IsHexAddress(args[0]) {
-				fmt.Println("invalid hex address")
+			// the chain ID of the NEW address is checked with API if not cached yet
+			var newChainID uint64
+			address, err := parseAddress(args[0])
+			if err == errUnknownChainID {
+				newChainID, address, err = utils.DecodeNewAddress(args[0])
+			}
+			if err != nil {
+				cli.fail(fmt.Errorf("invalid address: %v", err))
 				return
 			}
-			address := common.HexToAddress(args[0])
 
 			rpcurl := viper.GetString("Client.RPCUrl")
 			if rpcurl == "" {
@@ -30,23 +52,43 @@ func (cli *CLI) buildInfoCmd() *cobra.Command {
 
 			client, err := newtonclient.Dial(rpcurl)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
-			info, err := client.GetBaseInfo(context.Background(), address)
+			var blockNumber *big.Int
+			if cmd.Flags().Changed("block") {
+				number, err := cmd.Flags().GetUint64("block")
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				blockNumber = new(big.Int).SetUint64(number)
+			}
+
+			info, err := client.GetBaseInfoAt(context.Background(), address, blockNumber, nil)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
+				return
+			}
+			if newChainID != 0 && newChainID != info.NetworkID {
+				cli.fail(fmt.Errorf("the NEW address is on chain %d, not %d", newChainID, info.NetworkID))
 				return
 			}
 
-			fmt.Println("The base info is as follow: ")
-			fmt.Println("Address: ", address.String())
-			fmt.Println("NonceLatest: ", info.NonceLatest)
-			fmt.Println("NoncePending: ", info.NoncePending)
-			fmt.Println("Balance: ", getWeiAmountTextByUnit(info.Balance, UnitETH))
-			fmt.Println("GasPrice: ", getWeiAmountTextByUnit(info.GasPrice, UnitETH))
-			fmt.Println("ChainID: ", info.NetworkID)
+			result := &infoResult{
+				Address:      address,
+				AddressNEW:   utils.AddressToNew(info.NetworkID, address),
+				NonceLatest:  info.NonceLatest,
+				NoncePending: info.NoncePending,
+				Balance:      getWeiAmountTextByUnit(info.Balance, UnitETH),
+				GasPrice:     getWeiAmountTextByUnit(info.GasPrice, UnitETH),
+				ChainID:      info.NetworkID,
+				BlockHash:    info.BlockHash,
+			}
+			if info.BlockNumber != nil {
+				result.BlockNumber = info.BlockNumber.Uint64()
+			}
 
 			update, _ := cmd.Flags().GetBool("update")
 			if update {
@@ -59,21 +101,35 @@ func (cli *CLI) buildInfoCmd() *cobra.Command {
 					viper.Set("Client.RPCURL", rpcurl)
 				}
 				viper.Set(fmt.Sprintf("Client.%s.NonceLatest", address.String()), info.NonceLatest)
-				viper.Set(fmt.Sprintf("Client.%s.NoncePending", address.String()), info.NoncePending)
 				viper.Set(fmt.Sprintf("Client.%s.Balance", address.String()), info.Balance.String())
 
 				err = viper.WriteConfigAs(cli.config)
 				if err != nil {
-					fmt.Println("WriteConfig:", err)
+					cli.fail(fmt.Errorf("write config: %v", err))
+					return
+				}
+				result.ConfigFile = cli.config
+
+				// reconcile the local nonce with the pending nonce
+				ns, err := openNonceStore(nonceFile())
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				defer ns.Close()
+				if err := ns.set(info.NetworkID, address, info.NoncePending); err != nil {
+					cli.fail(err)
 					return
 				}
-				fmt.Println("Update info to config: ", cli.config)
+				result.NonceFile = ns.path
 			}
 
+			cli.output(result)
 		},
 	}
 
 	cmd.Flags().BoolP("update", "u", false, "update local info to config")
+	cmd.Flags().Uint64("block", 0, "the block `number` of the info, default is latest")
 
 	return cmd
 }
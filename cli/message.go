@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/utils"
+	"github.com/spf13/cobra"
+)
+
+// messageHash returns the hash of the EIP-712 typed data file if set, otherwise of the personal_sign message
+func messageHash(cmd *cobra.Command, args []string) ([]byte, error) {
+	typedDataFile, _ := cmd.Flags().GetString("typed-data")
+	if typedDataFile == "" {
+		if len(args) == 0 {
+			return nil, errors.New("message or --typed-data is required")
+		}
+		return utils.TextHash([]byte(args[0])), nil
+	}
+	if len(args) > 0 {
+		return nil, errors.New("only one of message and --typed-data can be set")
+	}
+
+	data, err := ioutil.ReadFile(typedDataFile)
+	if err != nil {
+		return nil, err
+	}
+	typedData, err := utils.ParseTypedData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return typedData.Hash()
+}
+
+// signMessage signs the hash with the keystore, the recovery ID is searched as the server does
+func (cli *CLI) signMessage(from common.Address, hash []byte) ([]byte, error) {
+	wallet := keystore.NewKeyStore(cli.walletPath, keystore.LightScryptN, keystore.LightScryptP)
+	account := accounts.Account{Address: from}
+
+	prompt := fmt.Sprintf("Unlocking account %s to sign message", from.String())
+	walletPassword, err := getPassPhrase(prompt, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := wallet.Unlock(account, walletPassword); err != nil {
+		return nil, err
+	}
+	defer wallet.Lock(from)
+
+	sign, err := wallet.SignHash(account, hash)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := utils.RecoverSignature(hash, sign, from)
+	if err != nil {
+		return nil, err
+	}
+	// V is 27 or 28 as personal_sign
+	signature[64] += 27
+
+	return signature, nil
+}
+
+type signMessageResult struct {
+	Address   common.Address `json:"address"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// verifyMessageResult is the result of the signature, Signer is the recovered signer of the invalid signature
+type verifyMessageResult struct {
+	Address common.Address  `json:"address"`
+	Valid   bool            `json:"valid"`
+	Signer  *common.Address `json:"signer,omitempty"`
+}
+
+func (cli *CLI) buildSignMessageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "sign-message <address> [message] [--typed-data file]",
+		Short:                 "Sign the message as personal_sign, or the EIP-712 typed data, to prove the ownership of the address",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			from, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("address error: %v", err))
+				return
+			}
+
+			hash, err := messageHash(cmd, args[1:])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			signature, err := cli.signMessage(from, hash)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(&signMessageResult{Address: from, Signature: signature})
+		},
+	}
+
+	cmd.Flags().String("typed-data", "", "the EIP-712 typed data JSON `file` as eth_signTypedData")
+	return cmd
+}
+
+func (cli *CLI) buildVerifyMessageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "verify-message <address> <signature> [message] [--typed-data file]",
+		Short:                 "Verify the signature of the message or the EIP-712 typed data is signed by the address",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.RangeArgs(2, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("address error: %v", err))
+				return
+			}
+			sign, err := hexutil.Decode(args[1])
+			if err != nil {
+				cli.fail(fmt.Errorf("signature error: %v", err))
+				return
+			}
+
+			hash, err := messageHash(cmd, args[2:])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			result := &verifyMessageResult{Address: address, Valid: true}
+			if _, err := utils.RecoverSignature(hash, sign, address); err != nil {
+				if err != utils.ErrUnrecoverableSignature {
+					cli.fail(err)
+					return
+				}
+				result.Valid = false
+				if signer, err := utils.RecoverSigner(hash, sign); err == nil {
+					result.Signer = &signer
+				}
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().String("typed-data", "", "the EIP-712 typed data JSON `file` as eth_signTypedData")
+	return cmd
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/utils"
+)
+
+func TestVerifyMessageOutput(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	hash := utils.TextHash([]byte("hello"))
+	sign, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := utils.RecoverSignature(hash, sign, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli := NewCLI()
+	output := cli.TestCommand(fmt.Sprintf("--output json verify-message %s %s hello", address.String(), hexutil.Encode(signature)))
+	var result verifyMessageResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil || !result.Valid || result.Address != address {
+		t.Errorf("valid output mismatch: %s", output)
+	}
+
+	other := common.HexToAddress("0x97549E368AcaFdCAE786BB93D98379f1D1561a29")
+	output = cli.TestCommand(fmt.Sprintf("--output json verify-message %s %s hello", other.String(), hexutil.Encode(signature)))
+	result = verifyMessageResult{}
+	if err := json.Unmarshal([]byte(output), &result); err != nil || result.Valid || result.Signer == nil || *result.Signer != address {
+		t.Errorf("invalid output mismatch: %s", output)
+	}
+}
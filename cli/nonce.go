@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gofrs/flock"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultNonceFile = "./nonces.json"
+
+// nonceStore is the next nonces of the addresses per chain in a local JSON file,
+// it is locked while opened so the concurrent CLI processes never use the same nonce
+type nonceStore struct {
+	path   string
+	lock   *flock.Flock
+	nonces map[string]uint64 // <chainID>/<address> => the next nonce
+}
+
+// nonceFile returns the path of the nonce store, the relative path is in the directory of the config file
+func nonceFile() string {
+	viper.SetDefault("Client.NonceFile", defaultNonceFile)
+	return relativeToConfig(viper.ConfigFileUsed(), viper.GetString("Client.NonceFile"))
+}
+
+// relativeToConfig returns the path in the directory of the config file if the path is relative
+func relativeToConfig(configFile, path string) string {
+	if configFile == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
+}
+
+// openNonceStore locks and loads the nonce store, it waits if locked by another process
+func openNonceStore(path string) (*nonceStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	lock := flock.New(path + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		fmt.Fprintln(os.Stderr, "Waiting for the nonce store locked by another process...")
+		if err := lock.Lock(); err != nil {
+			return nil, err
+		}
+	}
+
+	ns := &nonceStore{path: path, lock: lock, nonces: make(map[string]uint64)}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		lock.Unlock()
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &ns.nonces); err != nil {
+			lock.Unlock()
+			return nil, err
+		}
+	}
+
+	return ns, nil
+}
+
+// Close releases the lock
+func (ns *nonceStore) Close() error {
+	return ns.lock.Unlock()
+}
+
+func nonceKey(chainID uint64, address common.Address) string {
+	return fmt.Sprintf("%d/%s", chainID, strings.ToLower(address.Hex()))
+}
+
+// next returns the next nonce of the address, false if not tracked yet
+func (ns *nonceStore) next(chainID uint64, address common.Address) (uint64, bool) {
+	nonce, ok := ns.nonces[nonceKey(chainID, address)]
+	return nonce, ok
+}
+
+// set sets the next nonce of the address and saves the store
+func (ns *nonceStore) set(chainID uint64, address common.Address, nonce uint64) error {
+	ns.nonces[nonceKey(chainID, address)] = nonce
+	return ns.save()
+}
+
+// advance sets the next nonce after the submitted nonce, the nonce never goes back
+func (ns *nonceStore) advance(chainID uint64, address common.Address, submitted uint64) error {
+	if next, ok := ns.next(chainID, address); ok && next > submitted {
+		return nil
+	}
+	return ns.set(chainID, address, submitted+1)
+}
+
+func (ns *nonceStore) save() error {
+	data, err := json.MarshalIndent(ns.nonces, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file and rename, so the store is never partial
+	tmp := ns.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ns.path)
+}
+
+// pendingNonce returns the next nonce of the address from the store,
+// or reconciles it with the pending nonce from API if not tracked yet
+func (ns *nonceStore) pendingNonce(ctx context.Context, rpcurl string, chainID uint64, address common.Address) (uint64, error) {
+	if nonce, ok := ns.next(chainID, address); ok {
+		return nonce, nil
+	}
+
+	client, err := newtonclient.Dial(rpcurl)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	return ns.reconcile(ctx, client, chainID, address)
+}
+
+// reconcile sets the next nonce of the address to the pending nonce from API
+func (ns *nonceStore) reconcile(ctx context.Context, client *newtonclient.Client, chainID uint64, address common.Address) (uint64, error) {
+	info, err := client.GetBaseInfo(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	if info.NetworkID != chainID {
+		return 0, fmt.Errorf("the chain ID of API is %d, not %d", info.NetworkID, chainID)
+	}
+
+	if err := ns.set(chainID, address, info.NoncePending); err != nil {
+		return 0, err
+	}
+
+	return info.NoncePending, nil
+}
+
+// nonceResult is the next nonce of the address in the local nonce store,
+// Local is null if not tracked, Updated is the next nonce set by --set or --sync
+type nonceResult struct {
+	Address common.Address `json:"address"`
+	ChainID uint64         `json:"chainId"`
+	Local   *uint64        `json:"local"`
+	Updated *uint64        `json:"updated,omitempty"`
+}
+
+func (cli *CLI) buildNonceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "nonce <address> [--sync] [--set nonce]",
+		Short:                 "Show, reconcile with API or set the next nonce of the address in the local nonce store",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("invalid address: %v", err))
+				return
+			}
+			chainID := viper.GetUint64("Client.ChainID")
+			if chainID == 0 {
+				cli.fail(errors.New("get chainID from config error, run info --update first"))
+				return
+			}
+
+			ns, err := openNonceStore(nonceFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer ns.Close()
+
+			result := &nonceResult{Address: address, ChainID: chainID}
+			if nonce, ok := ns.next(chainID, address); ok {
+				result.Local = &nonce
+			}
+
+			if cmd.Flags().Changed("set") {
+				nonce, _ := cmd.Flags().GetUint64("set")
+				if err := ns.set(chainID, address, nonce); err != nil {
+					cli.fail(err)
+					return
+				}
+				result.Updated = &nonce
+			} else if sync, _ := cmd.Flags().GetBool("sync"); sync {
+				rpcurl := viper.GetString("Client.RPCUrl")
+				if rpcurl == "" {
+					rpcurl = cli.rpcURL
+				}
+				client, err := newtonclient.Dial(rpcurl)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				defer client.Close()
+
+				nonce, err := ns.reconcile(context.Background(), client, chainID, address)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				result.Updated = &nonce
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().Bool("sync", false, "reconcile the next nonce with the pending nonce from API")
+	cmd.Flags().Uint64("set", 0, "set the next `nonce`")
+
+	return cmd
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNonceStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nonces.json")
+	address := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+
+	ns, err := openNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ns.next(1007, address); ok {
+		t.Fatal("nonce tracked in the empty store")
+	}
+	if err := ns.advance(1007, address, 5); err != nil {
+		t.Fatal(err)
+	}
+	// the nonce never goes back when an earlier tx is submitted
+	if err := ns.advance(1007, address, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.set(1012, address, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err = openNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+	if nonce, ok := ns.next(1007, address); !ok || nonce != 6 {
+		t.Errorf("next nonce = %d, %v, want 6", nonce, ok)
+	}
+	if nonce, ok := ns.next(1012, address); !ok || nonce != 1 {
+		t.Errorf("next nonce of another chain = %d, %v, want 1", nonce, ok)
+	}
+}
+
+func TestRelativeToConfig(t *testing.T) {
+	tests := []struct {
+		config, path, want string
+	}{
+		{"", "./nonces.json", "./nonces.json"},
+		{"/etc/newchain/config.toml", "./nonces.json", "/etc/newchain/nonces.json"},
+		{"/etc/newchain/config.toml", "/var/lib/nonces.json", "/var/lib/nonces.json"},
+		{"config.toml", "./nonces.json", "nonces.json"},
+	}
+	for _, tt := range tests {
+		if got := relativeToConfig(tt.config, tt.path); got != tt.want {
+			t.Errorf("relativeToConfig(%q, %q) = %q, want %q", tt.config, tt.path, got, tt.want)
+		}
+	}
+}
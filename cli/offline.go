@@ -0,0 +1,445 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultTxFile = "./tx.json"
+
+// offlineTx is the tx file passed between tx build, tx sign and tx submit
+type offlineTx struct {
+	From      common.Address  `json:"from"`
+	To        *common.Address `json:"to"`
+	Value     *hexutil.Big    `json:"value"`
+	Nonce     hexutil.Uint64  `json:"nonce"`
+	GasPrice  *hexutil.Big    `json:"gasPrice"`
+	Gas       hexutil.Uint64  `json:"gas"`
+	Data      hexutil.Bytes   `json:"data,omitempty"`
+	ChainID   uint64          `json:"chainId"`
+	Tx        hexutil.Bytes   `json:"tx"`                  // the unsigned RLP
+	Hash      common.Hash     `json:"hash"`                // the signing hash
+	Signature hexutil.Bytes   `json:"signature,omitempty"` // [R || S || V], set by tx sign
+}
+
+// newOfflineTx builds the unsigned tx of the nonce with the gas price, gas limit and chain ID cached by info --update
+func newOfflineTx(from common.Address, to *common.Address, amount *big.Int, data []byte, gasLimit, nonce uint64) (*offlineTx, error) {
+	gasPrice, ok := big.NewInt(0).SetString(viper.GetString("Client.GasPrice"), 10)
+	if !ok {
+		return nil, errors.New("get gas price from config error")
+	}
+	if gasLimit == 0 {
+		gasLimit = uint64(viper.GetInt64("Client.GasLimit"))
+	}
+	chainID, ok := big.NewInt(0).SetString(viper.GetString("Client.ChainID"), 10)
+	if !ok {
+		return nil, errors.New("get chainID from config error")
+	}
+
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(nonce, amount, gasLimit, gasPrice, data)
+	} else {
+		tx = types.NewTransaction(nonce, *to, amount, gasLimit, gasPrice, data)
+	}
+	rlpTx, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offlineTx{
+		From:     from,
+		To:       to,
+		Value:    (*hexutil.Big)(amount),
+		Nonce:    hexutil.Uint64(nonce),
+		GasPrice: (*hexutil.Big)(gasPrice),
+		Gas:      hexutil.Uint64(gasLimit),
+		Data:     data,
+		ChainID:  chainID.Uint64(),
+		Tx:       rlpTx,
+		Hash:     types.NewEIP155Signer(chainID).Hash(tx),
+	}, nil
+}
+
+// transaction decodes the unsigned RLP and checks it matches the fields and the signing hash,
+// so the signer never signs a hash which is not the tx shown
+func (otx *offlineTx) transaction() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(otx.Tx, tx); err != nil {
+		return nil, err
+	}
+
+	if tx.Nonce() != uint64(otx.Nonce) || tx.Gas() != uint64(otx.Gas) ||
+		otx.GasPrice == nil || tx.GasPrice().Cmp(otx.GasPrice.ToInt()) != 0 ||
+		otx.Value == nil || tx.Value().Cmp(otx.Value.ToInt()) != 0 ||
+		!bytes.Equal(tx.Data(), otx.Data) || !addressEqual(tx.To(), otx.To) {
+		return nil, errors.New("the tx does not match the fields")
+	}
+
+	signer := types.NewEIP155Signer(new(big.Int).SetUint64(otx.ChainID))
+	if signer.Hash(tx) != otx.Hash {
+		return nil, errors.New("the hash does not match the tx")
+	}
+
+	return tx, nil
+}
+
+// signedTransaction returns the tx with the signature and checks it is signed by from
+func (otx *offlineTx) signedTransaction() (*types.Transaction, error) {
+	if len(otx.Signature) != 65 {
+		return nil, errors.New("the tx is not signed")
+	}
+	tx, err := otx.transaction()
+	if err != nil {
+		return nil, err
+	}
+
+	signer := types.NewEIP155Signer(new(big.Int).SetUint64(otx.ChainID))
+	signedTx, err := tx.WithSignature(signer, otx.Signature)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if sender != otx.From {
+		return nil, fmt.Errorf("the tx is signed by %s, not %s", sender.String(), otx.From.String())
+	}
+
+	return signedTx, nil
+}
+
+// show writes the tx to stderr, so it is checked before the password prompt and the output can be piped
+func (otx *offlineTx) show() {
+	fmt.Fprintln(os.Stderr, "The tx is as follow: ")
+	fmt.Fprintln(os.Stderr, "From: ", otx.From.String())
+	fmt.Fprintln(os.Stderr, "To: ", addressText(otx.To))
+	fmt.Fprintln(os.Stderr, "Amount: ", getWeiAmountTextByUnit(otx.Value.ToInt(), UnitETH))
+	fmt.Fprintln(os.Stderr, "Nonce: ", uint64(otx.Nonce))
+	fmt.Fprintln(os.Stderr, "GasPrice: ", otx.GasPrice.ToInt().String())
+	fmt.Fprintln(os.Stderr, "Gas: ", uint64(otx.Gas))
+	if len(otx.Data) > 0 {
+		fmt.Fprintln(os.Stderr, "Data: ", otx.Data.String())
+	}
+	fmt.Fprintln(os.Stderr, "ChainID: ", otx.ChainID)
+	fmt.Fprintln(os.Stderr, "Hash to sign: ", otx.Hash.String())
+}
+
+// offlineTxResult is the tx file saved by tx build or tx sign, the amount is in NEW
+type offlineTxResult struct {
+	File     string         `json:"file"`
+	From     common.Address `json:"from"`
+	To       string         `json:"to"`
+	Amount   string         `json:"amount"`
+	Nonce    uint64         `json:"nonce"`
+	GasPrice string         `json:"gasPrice"`
+	Gas      uint64         `json:"gas"`
+	Data     hexutil.Bytes  `json:"data,omitempty"`
+	ChainID  uint64         `json:"chainId"`
+	Hash     common.Hash    `json:"hash"` // the signing hash
+	Signed   bool           `json:"signed"`
+}
+
+func (otx *offlineTx) result(file string) *offlineTxResult {
+	return &offlineTxResult{
+		File:     file,
+		From:     otx.From,
+		To:       addressText(otx.To),
+		Amount:   getWeiAmountTextByUnit(otx.Value.ToInt(), UnitETH),
+		Nonce:    uint64(otx.Nonce),
+		GasPrice: otx.GasPrice.ToInt().String(),
+		Gas:      uint64(otx.Gas),
+		Data:     otx.Data,
+		ChainID:  otx.ChainID,
+		Hash:     otx.Hash,
+		Signed:   len(otx.Signature) > 0,
+	}
+}
+
+// submitResult is the submitted tx of tx submit and contract send
+type submitResult struct {
+	From  common.Address `json:"from"`
+	Nonce uint64         `json:"nonce"`
+	Hash  common.Hash    `json:"hash"`
+}
+
+func loadOfflineTx(path string) (*offlineTx, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	otx := new(offlineTx)
+	if err := json.Unmarshal(data, otx); err != nil {
+		return nil, err
+	}
+
+	return otx, nil
+}
+
+func (otx *offlineTx) save(path string) error {
+	data, err := json.MarshalIndent(otx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// signOfflineTx signs the hash of the tx with the keystore, no network is needed
+func (cli *CLI) signOfflineTx(otx *offlineTx) error {
+	if _, err := otx.transaction(); err != nil {
+		return err
+	}
+
+	wallet := keystore.NewKeyStore(cli.walletPath, keystore.LightScryptN, keystore.LightScryptP)
+	account := accounts.Account{Address: otx.From}
+
+	prompt := fmt.Sprintf("Unlocking account %s to sign tx", otx.From.String())
+	walletPassword, err := getPassPhrase(prompt, false)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Unlock(account, walletPassword); err != nil {
+		return err
+	}
+	defer wallet.Lock(otx.From)
+
+	signature, err := wallet.SignHash(account, otx.Hash.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(signature) != 65 {
+		return errors.New("signature len error")
+	}
+	otx.Signature = signature
+
+	return nil
+}
+
+// submitOfflineTx sends the signed tx by newton_sendTransaction, or newton_sendRawTransaction if raw
+func submitOfflineTx(otx *offlineTx, wait uint64, raw bool) (common.Hash, error) {
+	signedTx, err := otx.signedTransaction()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	client, err := newtonclient.Dial(viper.GetString("Client.RPCUrl"))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if raw {
+		return client.SendRawTransaction(ctx, signedTx, wait)
+	}
+	return client.SendTransaction(ctx, otx.Tx, otx.Signature[:64], otx.From, wait)
+}
+
+// advanceNonce advances the next nonce of the submitted tx in the nonce store
+func advanceNonce(ns *nonceStore, otx *offlineTx) {
+	if err := ns.advance(otx.ChainID, otx.From, uint64(otx.Nonce)); err != nil {
+		fmt.Fprintln(os.Stderr, "Update nonce store:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Update nonce to: ", ns.path)
+}
+
+func (cli *CLI) buildTxBuildCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "build <to> <amount> <--from address> [--data hex] [--gas limit] [--nonce nonce] [--out file]",
+		Short:                 "Build the unsigned tx from the info cached by info --update and the nonce store",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			to, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("to address error: %v", err))
+				return
+			}
+
+			amount, err := getAmountWei(args[1], UnitETH)
+			if err != nil {
+				cli.fail(fmt.Errorf("amount error: %v", err))
+				return
+			}
+
+			fromStr, _ := cmd.Flags().GetString("from")
+			from, err := parseAddress(fromStr)
+			if err != nil {
+				cli.fail(fmt.Errorf("from address error: %v", err))
+				return
+			}
+
+			var data []byte
+			if dataStr, _ := cmd.Flags().GetString("data"); dataStr != "" {
+				data, err = hexutil.Decode(dataStr)
+				if err != nil {
+					cli.fail(fmt.Errorf("data error: %v", err))
+					return
+				}
+			}
+			gas, _ := cmd.Flags().GetUint64("gas")
+
+			// hold the nonce store until the nonce is reserved, so the concurrent builds never use the same nonce
+			ns, err := openNonceStore(nonceFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer ns.Close()
+
+			chainID := viper.GetUint64("Client.ChainID")
+			nonce, _ := cmd.Flags().GetUint64("nonce")
+			if !cmd.Flags().Changed("nonce") {
+				var ok bool
+				nonce, ok = ns.next(chainID, from)
+				if !ok {
+					cli.fail(errors.New("the nonce of the address is not tracked, run info --update or set --nonce"))
+					return
+				}
+			}
+
+			otx, err := newOfflineTx(from, &to, amount, data, gas, nonce)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			out, _ := cmd.Flags().GetString("out")
+			if err := otx.save(out); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			// reserve the nonce, roll back by nonce --set if the tx is discarded
+			if err := ns.advance(otx.ChainID, from, nonce); err != nil {
+				cli.fail(fmt.Errorf("reserve nonce error: %v", err))
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Reserve nonce %d in %s, run nonce %s --set %d if the tx is not submitted\n", nonce, ns.path, from.String(), nonce)
+
+			cli.output(otx.result(out))
+		},
+	}
+
+	cmd.Flags().String("from", "", "the from address")
+	cmd.Flags().String("data", "", "the hex `data` of the tx")
+	cmd.Flags().Uint64("gas", 0, "the gas `limit`, default is Client.GasLimit of config")
+	cmd.Flags().Uint64("nonce", 0, "the `nonce` of the tx, default is the next nonce in the nonce store, which is reserved after built")
+	cmd.Flags().StringP("out", "o", defaultTxFile, "the `path` of the tx file")
+
+	return cmd
+}
+
+func (cli *CLI) buildTxSignCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "sign [file] [--out file]",
+		Short:                 "Sign the tx file with the keystore, no network is needed",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := defaultTxFile
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			otx, err := loadOfflineTx(path)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			otx.show()
+
+			if err := cli.signOfflineTx(otx); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			out, _ := cmd.Flags().GetString("out")
+			if out == "" {
+				out = path
+			}
+			if err := otx.save(out); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(otx.result(out))
+		},
+	}
+
+	cmd.Flags().StringP("out", "o", "", "the `path` of the signed tx file, default is the input file")
+
+	return cmd
+}
+
+func (cli *CLI) buildTxSubmitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "submit [file] [--wait level] [--raw]",
+		Short:                 "Submit the signed tx file to API",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := defaultTxFile
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			otx, err := loadOfflineTx(path)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			ns, err := openNonceStore(nonceFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer ns.Close()
+
+			wait, _ := cmd.Flags().GetUint64("wait")
+			raw, _ := cmd.Flags().GetBool("raw")
+			hash, err := submitOfflineTx(otx, wait, raw)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			advanceNonce(ns, otx)
+
+			cli.output(&submitResult{From: otx.From, Nonce: uint64(otx.Nonce), Hash: hash})
+		},
+	}
+
+	cmd.Flags().Uint64("wait", 1, "the wait level(0,1,2)")
+	cmd.Flags().Bool("raw", false, "submit as the signed raw tx")
+
+	return cmd
+}
+
+func addressEqual(a, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
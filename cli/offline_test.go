@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+)
+
+func TestOfflineTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+
+	viper.Set("Client.ChainID", 1007)
+	viper.Set("Client.GasPrice", "100")
+	viper.Set("Client.GasLimit", 21000)
+
+	otx, err := newOfflineTx(from, &to, big.NewInt(1000), nil, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(otx.Nonce) != 5 || uint64(otx.Gas) != 21000 || otx.ChainID != 1007 {
+		t.Fatalf("tx = %+v, want the cached info", otx)
+	}
+
+	if _, err := otx.signedTransaction(); err == nil {
+		t.Error("unsigned tx accepted")
+	}
+
+	otx.Signature, err = crypto.Sign(otx.Hash.Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := otx.signedTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedTx.Nonce() != 5 || *signedTx.To() != to {
+		t.Errorf("signed tx = %v, want the built tx", signedTx)
+	}
+
+	otx.Value.ToInt().SetInt64(2000)
+	if _, err := otx.transaction(); err == nil {
+		t.Error("tampered tx accepted")
+	}
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// the formats of --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+// errorResult is the error of the failed command in the output format
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+// output renders the result struct of the command to stdout in the --output format
+func (cli *CLI) output(result interface{}) {
+	if err := renderOutput(os.Stdout, cli.outputFormat, result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// fail renders the error in the --output format and exits with 1, so the scripts get the non-zero exit code
+func (cli *CLI) fail(err error) {
+	cli.output(errorResult{Error: err.Error()})
+	if !cli.testing {
+		os.Exit(1)
+	}
+}
+
+func renderOutput(w io.Writer, format string, result interface{}) error {
+	switch format {
+	case outputJSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case outputYAML:
+		data, err := marshalYAML(result)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return writeTable(w, result)
+	}
+}
+
+// marshalYAML marshals the result struct by the json tags, the order of the fields is kept
+func marshalYAML(result interface{}) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is YAML, the nested objects are decoded to MapSlice as the top level
+	var item yaml.MapSlice
+	if err := yaml.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(item)
+}
+
+// writeTable writes the fields of the result struct as "Name: value" lines,
+// and the slices as tables with the field names as the header
+func writeTable(w io.Writer, result interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	v := reflect.Indirect(reflect.ValueOf(result))
+	t := v.Type()
+	var tables []int
+	written := false
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if isRows(value) {
+			tables = append(tables, i)
+			continue
+		}
+		if omitEmpty(field) && isZero(value) {
+			continue
+		}
+		fmt.Fprintf(tw, "%s:\t%s\n", field.Name, cellText(value))
+		written = true
+	}
+	for _, i := range tables {
+		if omitEmpty(t.Field(i)) && v.Field(i).Len() == 0 {
+			continue
+		}
+		if written {
+			fmt.Fprintln(tw)
+		}
+		writeRows(tw, t.Field(i).Name, v.Field(i))
+		written = true
+	}
+
+	return tw.Flush()
+}
+
+// writeRows writes the slice of structs with the field names as the header,
+// or the slice of values with the name as the header
+func writeRows(tw *tabwriter.Writer, name string, rows reflect.Value) {
+	t := rows.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		fmt.Fprintln(tw, name)
+		for i := 0; i < rows.Len(); i++ {
+			fmt.Fprintln(tw, cellText(rows.Index(i)))
+		}
+		return
+	}
+
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			header = append(header, t.Field(i).Name)
+		}
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for i := 0; i < rows.Len(); i++ {
+		row := reflect.Indirect(rows.Index(i))
+		var cells []string
+		for j := 0; j < t.NumField(); j++ {
+			if t.Field(j).PkgPath == "" {
+				cells = append(cells, cellText(row.Field(j)))
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+}
+
+// isRows returns true for the slice except the bytes, which is written as a table
+func isRows(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+func omitEmpty(field reflect.StructField) bool {
+	return strings.Contains(field.Tag.Get("json"), ",omitempty")
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func cellText(v reflect.Value) string {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return ""
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(reflect.Indirect(v).Interface())
+}
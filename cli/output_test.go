@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRenderOutput(t *testing.T) {
+	address := common.HexToAddress("0x97549E368AcaFdCAE786BB93D98379f1D1561a29")
+	result := &balanceResult{
+		Count: 1,
+		Total: "1.5",
+		Accounts: []accountBalance{
+			{Address: address, Balance: "1.5"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := renderOutput(&buf, outputJSON, result); err != nil {
+		t.Fatal(err)
+	}
+	var decoded balanceResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Total != "1.5" || len(decoded.Accounts) != 1 || decoded.Accounts[0].Address != address {
+		t.Errorf("json output mismatch: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := renderOutput(&buf, outputYAML, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "count: 1\ntotal: \"1.5\"\naccounts:\n") {
+		t.Errorf("yaml output mismatch: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := renderOutput(&buf, outputTable, result); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[3], "Address") || !strings.HasPrefix(lines[4], address.String()) {
+		t.Errorf("table output mismatch: %s", buf.String())
+	}
+}
+
+func TestOutputError(t *testing.T) {
+	cli := NewCLI()
+
+	output := cli.TestCommand("--output json account balance 0x01")
+	var result errorResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil || result.Error == "" {
+		t.Errorf("error output mismatch: %s", output)
+	}
+}
@@ -3,18 +3,22 @@ package cli
 import (
 	"context"
 	"fmt"
-	"math/big"
+	"os"
 
-	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/rlp"
-	"github.com/newtonproject/newchain-api-express/newtonclient"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// payResult is the submitted payment, the amount is in NEW
+type payResult struct {
+	From   common.Address `json:"from"`
+	To     common.Address `json:"to"`
+	Amount string         `json:"amount"`
+	Nonce  uint64         `json:"nonce"`
+	Hash   common.Hash    `json:"hash"`
+}
+
 func (cli *CLI) buildPayCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:                   "pay <to> <amount> <--from address>",
@@ -22,116 +26,92 @@ func (cli *CLI) buildPayCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		Args:                  cobra.MinimumNArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			if !common.IsHexAddress(args[0]) {
-				fmt.Println("To address error")
+			to, err := parseAddress(args[0])
+			if err != nil {
+				cli.fail(fmt.Errorf("to address error: %v", err))
 				return
 			}
-			to := common.HexToAddress(args[0])
 
 			amount, err := getAmountWei(args[1], UnitETH)
 			if err != nil {
-				fmt.Println("Amount error: ", err)
+				cli.fail(fmt.Errorf("amount error: %v", err))
 				return
 			}
 
 			fromStr, err := cmd.Flags().GetString("from")
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
-			if !common.IsHexAddress(fromStr) {
-				fmt.Println("From address error")
+			from, err := parseAddress(fromStr)
+			if err != nil {
+				cli.fail(fmt.Errorf("from address error: %v", err))
 				return
 			}
-			from := common.HexToAddress(fromStr)
 
 			wait := int64(1)
 			if cmd.Flags().Changed("wait") {
 				wait, err = cmd.Flags().GetInt64("wait")
 				if err != nil {
-					fmt.Println(err)
+					cli.fail(err)
 					return
 				}
 			}
 
-			nonce := uint64(viper.GetInt64(fmt.Sprintf("Client.%s.NoncePending", from.String())))
-			gasPirce, ok := big.NewInt(0).SetString(viper.GetString("Client.GasPrice"), 10)
-			if !ok {
-				fmt.Println("Get gas price from config error")
-				return
-			}
-			gasLimit := uint64(viper.GetInt64("Client.GasLimit"))
-			chainId, ok := big.NewInt(0).SetString(viper.GetString("Client.ChainID"), 10)
-			if !ok {
-				fmt.Println("Get chainID from config error")
-				return
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
 			}
 
-			tx := types.NewTransaction(nonce, to, amount, gasLimit, gasPirce, nil)
-			message, err := rlp.EncodeToBytes(tx)
+			// hold the nonce store until submitted, so the concurrent pays never use the same nonce
+			ns, err := openNonceStore(nonceFile())
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
-			signer := types.NewEIP155Signer(chainId)
-			messageHash := signer.Hash(tx)
-
-			fmt.Println("The tx is as follow: ")
-			fmt.Println("To: ", to.String())
-			fmt.Println("Amount: ", getWeiAmountTextByUnit(amount, UnitETH))
-
-			wallet := keystore.NewKeyStore(cli.walletPath, keystore.LightScryptN, keystore.LightScryptP)
-
-			prompt := fmt.Sprintf("Unlocking account %s to sign tx", from.String())
-			walletPassword, err := getPassPhrase(prompt, false)
-
-			err = wallet.Unlock(accounts.Account{Address: from}, walletPassword)
+			defer ns.Close()
+			nonce, err := ns.pendingNonce(context.Background(), rpcurl, viper.GetUint64("Client.ChainID"), from)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
 
-			signature, err := wallet.SignHash(accounts.Account{Address: from}, messageHash.Bytes())
+			otx, err := newOfflineTx(from, &to, amount, nil, 0, nonce)
 			if err != nil {
-				fmt.Println(err)
-				return
-			}
-			if len(signature) != 65 {
-				fmt.Println("signature len error")
+				cli.fail(err)
 				return
 			}
 
-			rpcurl := viper.GetString("Client.RPCUrl")
-
-			client, err := newtonclient.Dial(rpcurl)
-			if err != nil {
-				fmt.Println(err)
+			if err := cli.signOfflineTx(otx); err != nil {
+				cli.fail(err)
 				return
 			}
 
-			ctx := context.Background()
-
-			hash, err := client.SendTransaction(ctx, message, signature[:64], from, uint64(wait))
+			hash, err := submitOfflineTx(otx, uint64(wait), false)
 			if err != nil {
-				fmt.Println(err)
+				cli.fail(err)
 				return
 			}
-			fmt.Println("Hash: ", hash.String())
 
-			// ok, update nonce
-			viper.Set(fmt.Sprintf("Client.%s.Nonce", from.String()), nonce+1)
-			err = viper.WriteConfigAs(cli.config)
-			if err != nil {
-				fmt.Println("WriteConfig:", err)
-				return
+			// ok, update nonce, the tx is submitted even if the nonce store fails
+			if err := ns.advance(otx.ChainID, otx.From, uint64(otx.Nonce)); err != nil {
+				fmt.Fprintln(os.Stderr, "Update nonce store:", err)
 			}
-			fmt.Println("Update nonce to config: ", cli.config)
 
+			cli.output(&payResult{
+				From:   from,
+				To:     to,
+				Amount: getWeiAmountTextByUnit(amount, UnitETH),
+				Nonce:  nonce,
+				Hash:   hash,
+			})
 		},
 	}
 
 	cmd.Flags().String("from", "", "the from address")
 	cmd.Flags().Int64("wait", 1, "the wait level(0,1,2)")
 
+	cmd.AddCommand(cli.buildPayBatchCmd()) // pay batch
+
 	return cmd
 }
@@ -0,0 +1,532 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/newtonproject/newchain-api-express/api"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/newtonproject/newchain-api-express/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// payBatchSize is the number of txs submitted in one newton_sendRawTransactions call
+const payBatchSize = 100
+
+// status of the payment in the results file
+const (
+	paymentSigned = "signed"
+	paymentFailed = "failed"
+)
+
+// errPreviousNonceFailed is the error of the tx rejected by the server
+// because the tx with the previous nonce of the batch failed, so it never entered the pool
+const errPreviousNonceFailed = "transaction with previous nonce of the sender failed"
+
+// errTxNotFound is the error of the server if the node does not know the tx
+const errTxNotFound = "transaction not found"
+
+// errTxReverted is the error of the payment whose tx was mined but reverted
+const errTxReverted = "transaction reverted"
+
+var paymentResultsHeader = []string{"row", "to", "amount", "nonce", "hash", "status", "error", "tx"}
+
+// payment is a row of the batch and its result
+type payment struct {
+	row    int
+	to     common.Address
+	amount string
+	value  *big.Int
+
+	nonce  uint64
+	hash   common.Hash
+	status string
+	err    string
+	tx     *types.Transaction
+
+	replace bool // sign again with the same nonce
+}
+
+// done returns true if the payment has been accepted by the server
+func (p *payment) done() bool {
+	return p.tx != nil && p.status != paymentSigned && p.status != paymentFailed
+}
+
+// paymentCount is the number of the payments of the status
+type paymentCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// payBatchResult is the summary of the batch, the result of each payment is in the results file
+type payBatchResult struct {
+	Payments int            `json:"payments"`
+	Results  string         `json:"results"`
+	Statuses []paymentCount `json:"statuses"`
+}
+
+func newPayBatchResult(payments []*payment, out string) *payBatchResult {
+	counts := make(map[string]int)
+	for _, p := range payments {
+		counts[p.status]++
+	}
+
+	result := &payBatchResult{Payments: len(payments), Results: out, Statuses: []paymentCount{}}
+	for status, count := range counts {
+		result.Statuses = append(result.Statuses, paymentCount{Status: status, Count: count})
+	}
+	sort.Slice(result.Statuses, func(i, j int) bool {
+		return result.Statuses[i].Status < result.Statuses[j].Status
+	})
+
+	return result
+}
+
+func (cli *CLI) buildPayBatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "batch <file.csv> <--from address> [--wait level] [--out results.csv]",
+		Short:                 "Pay to the addresses with the amounts in the CSV file (to,amount), resumable with the results file",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			fromStr, _ := cmd.Flags().GetString("from")
+			from, err := parseAddress(fromStr)
+			if err != nil {
+				cli.fail(fmt.Errorf("from address error: %v", err))
+				return
+			}
+
+			wait, _ := cmd.Flags().GetUint64("wait")
+			out, _ := cmd.Flags().GetString("out")
+			if out == "" {
+				out = strings.TrimSuffix(args[0], ".csv") + ".results.csv"
+			}
+
+			payments, err := readPayments(args[0])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			if err := loadPaymentResults(out, payments); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
+			}
+			client, err := newtonclient.Dial(rpcurl)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			// hold the nonce store until submitted, so the concurrent pays never use the same nonces
+			ns, err := openNonceStore(nonceFile())
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer ns.Close()
+
+			if err := cli.signPayments(ctx, client, ns, from, payments); err != nil {
+				cli.fail(err)
+				return
+			}
+			// save the signed txs before submitting, so the same txs are resubmitted after interruption
+			if err := savePaymentResults(out, payments); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			err = submitPayments(ctx, client, payments, wait, out)
+			advancePayments(ns, from, payments)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			updatePaymentStatus(ctx, client, payments)
+			if err := savePaymentResults(out, payments); err != nil {
+				cli.fail(err)
+				return
+			}
+
+			cli.output(newPayBatchResult(payments, out))
+		},
+	}
+
+	cmd.Flags().String("from", "", "the from address")
+	cmd.Flags().Uint64("wait", 1, "the wait level(0,1,2)")
+	cmd.Flags().StringP("out", "o", "", "the `path` of the results file, default is <file>.results.csv")
+
+	return cmd
+}
+
+// readPayments reads the rows of to,amount, the header is optional
+func readPayments(path string) ([]*payment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var payments []*payment
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || (len(record) == 1 && record[0] == "") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: want to,amount", line)
+		}
+		if line == 1 && !common.IsHexAddress(record[0]) && !utils.IsNewAddress(record[0]) {
+			// header
+			continue
+		}
+
+		to, err := parseAddress(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: to address error: %v", line, err)
+		}
+		value, err := getAmountWei(record[1], UnitETH)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount error: %v", line, err)
+		}
+
+		payments = append(payments, &payment{
+			row:    line,
+			to:     to,
+			amount: record[1],
+			value:  value,
+		})
+	}
+
+	return payments, nil
+}
+
+// loadPaymentResults loads the signed txs and results of the previous run if the results file exists
+func loadPaymentResults(path string, payments []*payment) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[int]*payment)
+	for _, p := range payments {
+		rows[p.row] = p
+	}
+	for _, record := range records {
+		if len(record) != len(paymentResultsHeader) || record[0] == paymentResultsHeader[0] {
+			continue
+		}
+		row, err := strconv.Atoi(record[0])
+		if err != nil {
+			return err
+		}
+		p, ok := rows[row]
+		if !ok || !strings.EqualFold(p.to.String(), record[1]) || p.amount != record[2] {
+			return fmt.Errorf("the results file %s does not match row %d", path, row)
+		}
+		if record[7] == "" {
+			continue
+		}
+
+		data, err := hexutil.Decode(record[7])
+		if err != nil {
+			return err
+		}
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(data, tx); err != nil {
+			return err
+		}
+		p.tx, p.nonce, p.hash = tx, tx.Nonce(), tx.Hash()
+		p.status, p.err = record[5], record[6]
+	}
+
+	return nil
+}
+
+func savePaymentResults(path string, payments []*payment) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(paymentResultsHeader)
+	for _, p := range payments {
+		record := []string{strconv.Itoa(p.row), p.to.String(), p.amount, "", "", p.status, p.err, ""}
+		if p.tx != nil {
+			data, err := rlp.EncodeToBytes(p.tx)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			record[3] = strconv.FormatUint(p.nonce, 10)
+			record[4] = p.hash.String()
+			record[7] = hexutil.Encode(data)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// checkFailedPayments checks the failed payments before signing them again.
+// The tx failed for the previous nonce never entered the pool, so it is signed with a new nonce.
+// Other failed txs may have been broadcast, e.g. timed out to be confirmed,
+// so the tx known by the node takes its status, otherwise it is signed again with the same nonce,
+// which can only replace the original tx.
+func checkFailedPayments(ctx context.Context, client *newtonclient.Client, payments []*payment) error {
+	for _, p := range payments {
+		if p.tx == nil || p.status != paymentFailed || p.err == errPreviousNonceFailed {
+			continue
+		}
+
+		tx, err := client.GetTransaction(ctx, p.hash)
+		if err != nil {
+			if err.Error() != errTxNotFound {
+				return fmt.Errorf("check failed row %d: %v", p.row, err)
+			}
+			p.replace = true
+			continue
+		}
+		if tx.Pending {
+			p.status, p.err = submittedStatus(1), ""
+			continue
+		}
+		if err := minedPaymentStatus(ctx, client, p); err != nil {
+			return fmt.Errorf("check failed row %d: %v", p.row, err)
+		}
+	}
+
+	return nil
+}
+
+// minedPaymentStatus sets the status of the mined tx of the failed payment.
+// The status tracked by the server is taken unless it is failed too, e.g. tracked before the tx was mined,
+// then the receipt decides, the reverted tx has used its nonce and is signed again with a new nonce.
+func minedPaymentStatus(ctx context.Context, client *newtonclient.Client, p *payment) error {
+	hash := p.hash
+	if submission, err := client.GetSubmission(ctx, &hash, ""); err == nil && submission.Status != api.StatusFailed {
+		p.status, p.err = submission.Status, submission.Error
+		return nil
+	}
+
+	receipt, err := client.GetReceipt(ctx, p.hash)
+	if err != nil {
+		return err
+	}
+	if receipt.Status == api.ReceiptStatusFailed {
+		p.status, p.err = paymentFailed, errTxReverted
+		return nil
+	}
+	p.status, p.err = submittedStatus(2), ""
+
+	return nil
+}
+
+// signPayments unlocks the account once and signs the payments which are not signed or failed,
+// the nonces are assigned sequentially from the pending nonce, the nonce store and after the signed txs,
+// except the failed txs which may have been broadcast keep their nonces
+func (cli *CLI) signPayments(ctx context.Context, client *newtonclient.Client, ns *nonceStore, from common.Address, payments []*payment) error {
+	if err := checkFailedPayments(ctx, client, payments); err != nil {
+		return err
+	}
+
+	var unsigned []*payment
+	for _, p := range payments {
+		if p.tx == nil || p.status == paymentFailed {
+			unsigned = append(unsigned, p)
+		}
+	}
+	if len(unsigned) == 0 {
+		return nil
+	}
+
+	info, err := client.GetBaseInfo(ctx, from)
+	if err != nil {
+		return err
+	}
+	nonce := info.NoncePending
+	if next, ok := ns.next(info.NetworkID, from); ok && next > nonce {
+		nonce = next
+	}
+	for _, p := range payments {
+		if p.tx != nil && (p.status != paymentFailed || p.replace) && p.nonce >= nonce {
+			nonce = p.nonce + 1
+		}
+	}
+	gasLimit := uint64(viper.GetInt64("Client.GasLimit"))
+	if gasLimit == 0 {
+		gasLimit = 21000
+	}
+	chainID := new(big.Int).SetUint64(info.NetworkID)
+
+	total := new(big.Int)
+	for _, p := range unsigned {
+		total.Add(total, p.value)
+	}
+	fmt.Fprintf(os.Stderr, "Sign %d payments of %s from %s\n", len(unsigned), getWeiAmountTextByUnit(total, UnitETH), from.String())
+
+	wallet := keystore.NewKeyStore(cli.walletPath, keystore.LightScryptN, keystore.LightScryptP)
+	account := accounts.Account{Address: from}
+	walletPassword, err := getPassPhrase(fmt.Sprintf("Unlocking account %s to sign txs", from.String()), false)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Unlock(account, walletPassword); err != nil {
+		return err
+	}
+	defer wallet.Lock(from)
+
+	for _, p := range unsigned {
+		n := nonce
+		if p.replace {
+			n = p.nonce
+		} else {
+			nonce++
+		}
+		tx := types.NewTransaction(n, p.to, p.value, gasLimit, info.GasPrice, nil)
+		signedTx, err := wallet.SignTx(account, tx, chainID)
+		if err != nil {
+			return err
+		}
+		p.tx, p.nonce, p.hash = signedTx, n, signedTx.Hash()
+		p.status, p.err, p.replace = paymentSigned, "", false
+	}
+
+	return nil
+}
+
+// submitPayments submits the signed payments in batches and saves the results after each batch
+func submitPayments(ctx context.Context, client *newtonclient.Client, payments []*payment, wait uint64, out string) error {
+	var pending []*payment
+	for _, p := range payments {
+		if p.status == paymentSigned {
+			pending = append(pending, p)
+		}
+	}
+
+	for start := 0; start < len(pending); start += payBatchSize {
+		end := start + payBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		txs := make([]*types.Transaction, len(batch))
+		for i, p := range batch {
+			txs[i] = p.tx
+		}
+		results, err := client.SendRawTransactions(ctx, txs, wait)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			p := batch[i]
+			if result.Error != nil {
+				p.status, p.err = paymentFailed, result.Error.Error()
+				continue
+			}
+			p.status = submittedStatus(wait)
+		}
+
+		if err := savePaymentResults(out, payments); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Submitted %d/%d\n", end, len(pending))
+	}
+
+	return nil
+}
+
+// advancePayments advances the next nonce after the payments accepted by the server
+func advancePayments(ns *nonceStore, from common.Address, payments []*payment) {
+	var submitted *payment
+	for _, p := range payments {
+		if p.done() && (submitted == nil || p.nonce > submitted.nonce) {
+			submitted = p
+		}
+	}
+	if submitted == nil {
+		return
+	}
+
+	chainID := submitted.tx.ChainId().Uint64()
+	if err := ns.advance(chainID, from, submitted.nonce); err != nil {
+		fmt.Fprintln(os.Stderr, "Update nonce store:", err)
+	}
+}
+
+// submittedStatus is the status reached when the submission of the wait level returns
+func submittedStatus(wait uint64) string {
+	switch wait {
+	case 1:
+		return "broadcast"
+	case 2:
+		return "confirmed"
+	default:
+		return "received"
+	}
+}
+
+// updatePaymentStatus updates the final status of the submitted payments from the server
+func updatePaymentStatus(ctx context.Context, client *newtonclient.Client, payments []*payment) {
+	for _, p := range payments {
+		if !p.done() {
+			continue
+		}
+		hash := p.hash
+		submission, err := client.GetSubmission(ctx, &hash, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Get status of row %d error: %v\n", p.row, err)
+			continue
+		}
+		p.status, p.err = submission.Status, submission.Error
+	}
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/newtonproject/newchain-api-express/rpc"
+)
+
+func TestPaymentResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paybatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "payroll.csv")
+	data := "to,amount\n" +
+		"0x97549e368acafdcae786bb93d98379f1d1561a29,1.5\n" +
+		"0xd639a62be604374ff04af4112a555890bd822a03,2\n"
+	if err := ioutil.WriteFile(input, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	payments, err := readPayments(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 2 || payments[0].row != 2 || payments[1].amount != "2" {
+		t.Fatalf("payments = %v, want 2 rows without header", payments)
+	}
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP155Signer(big.NewInt(1007))
+	tx, err := types.SignTx(types.NewTransaction(7, payments[0].to, payments[0].value, 21000, big.NewInt(100), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments[0].tx, payments[0].nonce, payments[0].hash, payments[0].status = tx, 7, tx.Hash(), paymentSigned
+
+	out := filepath.Join(dir, "payroll.results.csv")
+	if err := savePaymentResults(out, payments); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := readPayments(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadPaymentResults(out, resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed[0].hash != tx.Hash() || resumed[0].nonce != 7 || resumed[0].status != paymentSigned {
+		t.Errorf("resumed = %+v, want the signed tx", resumed[0])
+	}
+	if resumed[1].tx != nil {
+		t.Errorf("resumed = %+v, want unsigned", resumed[1])
+	}
+
+	// the results of another input are rejected
+	resumed[1].amount = "3"
+	if err := loadPaymentResults(out, resumed); err == nil {
+		t.Error("mismatched results accepted")
+	}
+}
+
+// NewtonService is the server of the test which knows the txs, their submissions and receipts,
+// rpc only registers exported types
+type NewtonService struct {
+	txs         map[common.Hash]*newtonclient.Transaction
+	submissions map[common.Hash]*newtonclient.Submission
+	receipts    map[common.Hash]*newtonclient.Receipt
+}
+
+func (s *NewtonService) GetSubmission(args struct {
+	Hash *common.Hash `json:"hash"`
+}) (*newtonclient.Submission, error) {
+	submission, ok := s.submissions[*args.Hash]
+	if !ok {
+		return nil, errors.New("submission not found")
+	}
+	return submission, nil
+}
+
+func (s *NewtonService) GetReceipt(args struct {
+	Hash common.Hash `json:"hash"`
+}) (*newtonclient.Receipt, error) {
+	receipt, ok := s.receipts[args.Hash]
+	if !ok {
+		return nil, errors.New("receipt not found")
+	}
+	return receipt, nil
+}
+
+func (s *NewtonService) GetTransaction(args struct {
+	Hash common.Hash `json:"hash"`
+}) (*newtonclient.Transaction, error) {
+	tx, ok := s.txs[args.Hash]
+	if !ok {
+		return nil, errors.New(errTxNotFound)
+	}
+	return tx, nil
+}
+
+func TestResumeFailedPayments(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP155Signer(big.NewInt(1007))
+	to := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+
+	var payments []*payment
+	reasons := []string{"context deadline exceeded", "context deadline exceeded", "insufficient funds for gas * price + value", errPreviousNonceFailed,
+		"context deadline exceeded", "context deadline exceeded"}
+	for i, reason := range reasons {
+		tx, err := types.SignTx(types.NewTransaction(uint64(10+i), to, big.NewInt(1), 21000, big.NewInt(100), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payments = append(payments, &payment{row: i + 2, to: to, tx: tx, nonce: tx.Nonce(), hash: tx.Hash(), status: paymentFailed, err: reason})
+	}
+
+	// the first tx timed out to be confirmed but was mined and tracked by the server,
+	// the last two were mined but tracked as failed or not tracked, the last one reverted
+	service := &NewtonService{
+		txs: map[common.Hash]*newtonclient.Transaction{
+			payments[0].hash: {Hash: payments[0].hash},
+			payments[4].hash: {Hash: payments[4].hash},
+			payments[5].hash: {Hash: payments[5].hash},
+		},
+		submissions: map[common.Hash]*newtonclient.Submission{
+			payments[0].hash: {Hash: payments[0].hash, Status: "confirmed"},
+			payments[4].hash: {Hash: payments[4].hash, Status: "failed", Error: "context deadline exceeded"},
+		},
+		receipts: map[common.Hash]*newtonclient.Receipt{
+			payments[4].hash: {Hash: payments[4].hash, Status: "success"},
+			payments[5].hash: {Hash: payments[5].hash, Status: "failed"},
+		},
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("newton", service); err != nil {
+		t.Fatal(err)
+	}
+	client := newtonclient.NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	if err := checkFailedPayments(context.Background(), client, payments); err != nil {
+		t.Fatal(err)
+	}
+	if p := payments[0]; p.status != "confirmed" || p.err != "" || p.replace || !p.done() {
+		t.Errorf("mined payment = %+v, want confirmed without signing again", p)
+	}
+	for _, p := range payments[1:3] {
+		if p.status != paymentFailed || !p.replace {
+			t.Errorf("unknown payment = %+v, want signed again with the same nonce", p)
+		}
+	}
+	if p := payments[3]; p.status != paymentFailed || p.replace {
+		t.Errorf("previous nonce failed payment = %+v, want signed with a new nonce", p)
+	}
+	if p := payments[4]; p.status != "confirmed" || p.err != "" || p.replace || !p.done() {
+		t.Errorf("mined payment tracked as failed = %+v, want confirmed by the receipt", p)
+	}
+	if p := payments[5]; p.status != paymentFailed || p.err != errTxReverted || p.replace {
+		t.Errorf("reverted payment = %+v, want failed and signed with a new nonce", p)
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/newtonclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// txResult is the transaction, the block fields are unset if pending, the value is in NEW
+type txResult struct {
+	Hash          common.Hash    `json:"hash"`
+	From          common.Address `json:"from"`
+	To            string         `json:"to"`
+	Nonce         uint64         `json:"nonce"`
+	Value         string         `json:"value"`
+	GasPrice      string         `json:"gasPrice"`
+	Gas           uint64         `json:"gas"`
+	Status        string         `json:"status"`
+	BlockNumber   uint64         `json:"blockNumber,omitempty"`
+	BlockHash     *common.Hash   `json:"blockHash,omitempty"`
+	Confirmations uint64         `json:"confirmations,omitempty"`
+}
+
+// receiptResult is the receipt, the value and fee are in NEW
+type receiptResult struct {
+	Hash            common.Hash     `json:"hash"`
+	Status          string          `json:"status"`
+	From            common.Address  `json:"from"`
+	To              string          `json:"to"`
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+	Value           string          `json:"value"`
+	GasUsed         uint64          `json:"gasUsed"`
+	Fee             string          `json:"fee"`
+	Logs            uint            `json:"logs"`
+	BlockNumber     uint64          `json:"blockNumber"`
+	BlockHash       common.Hash     `json:"blockHash"`
+	Confirmations   uint64          `json:"confirmations"`
+}
+
+func (cli *CLI) buildTxCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "tx <hash> [--receipt]",
+		Short:                 "Get the transaction or receipt from API",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			hash, err := parseHash(args[0])
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			rpcurl := viper.GetString("Client.RPCUrl")
+			if rpcurl == "" {
+				rpcurl = cli.rpcURL
+			}
+
+			client, err := newtonclient.Dial(rpcurl)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			if receipt, _ := cmd.Flags().GetBool("receipt"); receipt {
+				result, err := getReceipt(client, hash)
+				if err != nil {
+					cli.fail(err)
+					return
+				}
+				cli.output(result)
+				return
+			}
+
+			tx, err := client.GetTransaction(context.Background(), hash)
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			result := &txResult{
+				Hash:     tx.Hash,
+				From:     tx.From,
+				To:       addressText(tx.To),
+				Nonce:    uint64(tx.Nonce),
+				Value:    tx.ValueNEW,
+				GasPrice: tx.GasPrice.ToInt().String(),
+				Gas:      uint64(tx.Gas),
+				Status:   "included",
+			}
+			if tx.Pending {
+				result.Status = "pending"
+			} else {
+				result.BlockNumber = uint64(*tx.BlockNumber)
+				result.BlockHash = tx.BlockHash
+				result.Confirmations = uint64(tx.Confirmations)
+			}
+
+			cli.output(result)
+		},
+	}
+
+	cmd.Flags().BoolP("receipt", "r", false, "show the receipt of the transaction")
+
+	// offline signing
+	cmd.AddCommand(cli.buildTxBuildCmd())  // tx build
+	cmd.AddCommand(cli.buildTxSignCmd())   // tx sign
+	cmd.AddCommand(cli.buildTxSubmitCmd()) // tx submit
+
+	return cmd
+}
+
+func getReceipt(client *newtonclient.Client, hash common.Hash) (*receiptResult, error) {
+	receipt, err := client.GetReceipt(context.Background(), hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &receiptResult{
+		Hash:            receipt.Hash,
+		Status:          receipt.Status,
+		From:            receipt.From,
+		To:              addressText(receipt.To),
+		ContractAddress: receipt.ContractAddress,
+		Value:           receipt.ValueNEW,
+		GasUsed:         uint64(receipt.GasUsed),
+		Fee:             receipt.FeeNEW,
+		Logs:            uint(receipt.Logs),
+		BlockNumber:     uint64(receipt.BlockNumber),
+		BlockHash:       receipt.BlockHash,
+		Confirmations:   uint64(receipt.Confirmations),
+	}, nil
+}
+
+func addressText(address *common.Address) string {
+	if address == nil {
+		return "<contract creation>"
+	}
+	return address.String()
+}
+
+func parseHash(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid hash: %s", s)
+	}
+	return common.BytesToHash(b), nil
+}
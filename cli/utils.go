@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -12,9 +10,11 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/console"
-	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/newtonproject/newchain-api-express/utils"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -26,10 +26,22 @@ var (
 	errIllegalAmount       = errors.New("Illegal Amount")
 	errIllegalUnit         = errors.New("Illegal Unit")
 	errRequiredFromAddress = errors.New(`required flag(s) "from" not set`)
+	errUnknownChainID      = errors.New("unknown chain ID to check the NEW address, run info --update first")
 )
 
 var IsDecimalString = regexp.MustCompile(`^[1-9]\d*$|^0$|^0\.\d*$|^[1-9](\d)*\.(\d)*$`).MatchString
 
+// parseAddress parses the hex or NEW format address,
+// the chain ID of the NEW format is checked against Client.ChainID cached by info --update
+func parseAddress(s string) (common.Address, error) {
+	chainID := viper.GetUint64("Client.ChainID")
+	if chainID == 0 && utils.IsNewAddress(s) {
+		return common.Address{}, errUnknownChainID
+	}
+
+	return utils.ParseAddress(chainID, s)
+}
+
 func showSuccess(msg string, args ...interface{}) {
 	fmt.Printf(msg+"\n", args...)
 }
@@ -40,7 +52,12 @@ func showError(fields logrus.Fields, msg string, args ...interface{}) {
 
 // getPassPhrase retrieves the password associated with an account,
 // requested interactively from the user.
+// The prompts are written to stderr, so the output of the command can be piped.
 func getPassPhrase(prompt string, confirmation bool) (string, error) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
 	// prompt the user for the password
 	if prompt != "" {
 		fmt.Println(prompt)
@@ -182,32 +199,6 @@ func createNewAccount(walletPath string, numOfNew int) error {
 	return nil
 }
 
-func showTransactionReceipt(url, txStr string) {
-	sendJSONPostAndShow(url, "eth_getTransactionReceipt", txStr)
-}
-
-func sendJSONPostAndShow(url, method string, args ...interface{}) {
-	ctx := context.Background()
-	client, err := rpc.DialContext(ctx, url)
-	if err != nil {
-		fmt.Println("DialContext: ", err)
-		return
-	}
-	var raw json.RawMessage
-	if err := client.CallContext(ctx, &raw, method, args...); err != nil {
-		fmt.Println("CallContext Error: ", err)
-		return
-	}
-	rawStr, err := json.MarshalIndent(raw, "", "\t")
-	if err != nil {
-		fmt.Println("JSON marshaling failed: ", err)
-		return
-	}
-	fmt.Printf("%s\n", rawStr)
-
-	return
-}
-
 func getFaucet(rpcURL, address string) {
 	url := fmt.Sprintf("%s/faucet?address=%s", rpcURL, address)
 	resp, err := http.Get(url)
@@ -216,6 +207,6 @@ func getFaucet(rpcURL, address string) {
 		return
 	}
 	if resp.StatusCode == 200 {
-		fmt.Printf("Get faucet for %s\n", address)
+		fmt.Fprintf(os.Stderr, "Get faucet for %s\n", address)
 	}
 }
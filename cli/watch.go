@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/api"
+	"github.com/newtonproject/newchain-api-express/notification"
+	"github.com/spf13/cobra"
+)
+
+// watchEvent is a lifecycle notification of the tx, the value is in NEW except the token transfer
+type watchEvent struct {
+	Time        string         `json:"time"`
+	Stage       string         `json:"stage"`
+	Hash        common.Hash    `json:"hash"`
+	From        common.Address `json:"from"`
+	To          string         `json:"to"`
+	Value       string         `json:"value"` // in NEW, or the raw integer of the token transfer
+	Token       string         `json:"token,omitempty"`
+	BlockNumber uint64         `json:"blockNumber,omitempty"`
+	Status      string         `json:"status,omitempty"` // success or failed of the receipt
+	Error       string         `json:"error,omitempty"`
+}
+
+// decodeWatchEvent decodes the envelope, or the legacy TransferTx whose stage is the level of the topic
+func decodeWatchEvent(topic string, payload []byte) (*watchEvent, error) {
+	e, err := notification.Decode(payload)
+	if err != nil {
+		var tx api.TransferTx
+		if json.Unmarshal(payload, &tx) != nil {
+			return nil, err
+		}
+		level, _ := strconv.ParseInt(topic[strings.LastIndex(topic, "/")+1:], 10, 64)
+		stage, ok := notification.LevelStage(level)
+		if !ok {
+			return nil, fmt.Errorf("unknown stage of topic %s", topic)
+		}
+
+		event := &watchEvent{
+			Time:  time.Now().Format(time.RFC3339),
+			Stage: stage,
+			Hash:  tx.Hash,
+			From:  tx.From,
+			To:    addressText(tx.To),
+			Value: getWeiAmountTextByUnit(tx.Value, UnitETH),
+		}
+		if tx.Token != nil {
+			event.Token, event.Value = tx.Token.String(), tx.Value.String()
+		}
+		if tx.BlockNumber != nil {
+			event.BlockNumber = tx.BlockNumber.Uint64()
+		}
+		return event, nil
+	}
+
+	event := &watchEvent{
+		Time:  time.Unix(e.Timestamp, 0).Format(time.RFC3339),
+		Stage: e.Stage,
+		Hash:  e.Tx.Hash,
+		From:  e.Tx.From,
+		To:    addressText(e.Tx.To),
+		Value: getWeiAmountTextByUnit(e.Tx.Value.ToInt(), UnitETH),
+		Error: e.Error,
+	}
+	if e.Tx.Token != nil {
+		event.Token, event.Value = e.Tx.Token.String(), e.Tx.Value.ToInt().String()
+	}
+	if e.Receipt != nil {
+		event.BlockNumber = uint64(e.Receipt.BlockNumber)
+		event.Status = "success"
+		if e.Receipt.Status == 0 {
+			event.Status = "failed"
+		}
+	}
+
+	return event, nil
+}
+
+// reached returns true if the stage of the event is the until stage or later
+func (e *watchEvent) reached(until string) bool {
+	level, ok := notification.StageLevel(e.Stage)
+	if !ok {
+		return false
+	}
+	untilLevel, _ := notification.StageLevel(until)
+	return level >= untilLevel
+}
+
+// printEvent prints the event as a line, or in the --output format one by one
+func (cli *CLI) printEvent(e *watchEvent) {
+	switch cli.outputFormat {
+	case outputTable:
+		unit := UnitETH
+		if e.Token != "" {
+			unit = "of token " + e.Token
+		}
+		line := fmt.Sprintf("%s %-9s %s %s -> %s %s %s", e.Time, e.Stage, e.Hash.String(), e.From.String(), e.To, e.Value, unit)
+		if e.BlockNumber > 0 {
+			line += fmt.Sprintf(" block %d", e.BlockNumber)
+		}
+		if e.Status != "" {
+			line += " " + e.Status
+		}
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		fmt.Println(line)
+	case outputYAML:
+		fmt.Println("---")
+		cli.output(e)
+	default:
+		cli.output(e)
+	}
+}
+
+// subscribeNotifications connects to the broker of the [Notify] config and subscribes the topic on each connect,
+// the subscription is dropped with the clean session. The handler is called for the decoded events.
+func subscribeNotifications(notify *api.NotifyConfig, topic string, handler func(*watchEvent)) (mqtt.Client, error) {
+	opts, err := api.NewSubscriberOptions(notify, fmt.Sprintf("%s-watch-%d", notify.ClientID, os.Getpid()))
+	if err != nil {
+		return nil, err
+	}
+
+	callback := func(c mqtt.Client, msg mqtt.Message) {
+		event, err := decodeWatchEvent(msg.Topic(), msg.Payload())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Decode %s error: %v\n", msg.Topic(), err)
+			return
+		}
+		handler(event)
+	}
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		if token := c.Subscribe(topic, notify.QoS, callback); token.Wait() && token.Error() != nil {
+			fmt.Fprintf(os.Stderr, "Subscribe %s error: %v\n", topic, token.Error())
+		}
+	})
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		fmt.Fprintf(os.Stderr, "MQTT connection lost: %v, reconnecting...\n", err)
+	})
+
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return client, nil
+}
+
+func (cli *CLI) buildWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "watch <address|hash> [--to address] [--until stage] [--timeout duration]",
+		Short:                 "Tail the notifications of the txs to the address, or of the tx until the stage",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			until, _ := cmd.Flags().GetString("until")
+			if _, ok := notification.StageLevel(until); !ok {
+				cli.fail(fmt.Errorf("invalid stage %s, received, broadcast or confirmed", until))
+				return
+			}
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			// the hash is watched until the stage, the address is watched until interrupted
+			var hash *common.Hash
+			var topic string
+			if b, err := hexutil.Decode(args[0]); err == nil && len(b) == common.HashLength {
+				h := common.BytesToHash(b)
+				hash = &h
+			}
+
+			notify, err := loadNotifyConfig("")
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+
+			toStr, _ := cmd.Flags().GetString("to")
+			switch {
+			case hash == nil:
+				address, err := parseAddress(args[0])
+				if err != nil {
+					cli.fail(fmt.Errorf("invalid address or hash: %v", err))
+					return
+				}
+				topic = notification.AddressTopic(notify.PrefixTopic, address, "")
+			case toStr != "":
+				to, err := parseAddress(toStr)
+				if err != nil {
+					cli.fail(fmt.Errorf("invalid to address: %v", err))
+					return
+				}
+				topic = notification.AddressTopic(notify.PrefixTopic, to, "")
+			default:
+				// the retained last status, the server must publish it by RetainStatus
+				topic = notification.TxTopic(notify.PrefixTopic, *hash)
+			}
+
+			events := make(chan *watchEvent, 100)
+			client, err := subscribeNotifications(notify, topic, func(event *watchEvent) {
+				events <- event
+			})
+			if err != nil {
+				cli.fail(err)
+				return
+			}
+			defer client.Disconnect(250)
+			fmt.Fprintf(os.Stderr, "Watching %s\n", topic)
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			defer signal.Stop(interrupt)
+
+			var deadline <-chan time.Time
+			if timeout > 0 {
+				deadline = time.After(timeout)
+			}
+
+			for {
+				select {
+				case event := <-events:
+					if hash != nil && event.Hash != *hash {
+						continue
+					}
+					cli.printEvent(event)
+					if hash == nil {
+						continue
+					}
+					if event.Stage == notification.StageFailed {
+						client.Disconnect(250)
+						cli.fail(fmt.Errorf("tx %s failed: %s", hash.String(), event.Error))
+						return
+					}
+					if event.reached(until) {
+						return
+					}
+				case <-deadline:
+					client.Disconnect(250)
+					cli.fail(errors.New("timeout waiting for the stage " + until))
+					return
+				case <-interrupt:
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().String("to", "", "watch the hash on the topic of the receiver `address`, no need of RetainStatus on the server")
+	cmd.Flags().String("until", notification.StageConfirmed, "exit when the watched hash reaches the `stage`, received, broadcast or confirmed")
+	cmd.Flags().Duration("timeout", 0, "exit with error if the hash does not reach the stage in the `duration`, 0 for no timeout")
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/newtonproject/newchain-api-express/notification"
+)
+
+func TestDecodeWatchEvent(t *testing.T) {
+	to := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	hash := common.HexToHash("0x01")
+	topic := notification.AddressTopic("newchain/api", to, notification.StageBroadcast)
+
+	// the legacy TransferTx, the stage is the level of the topic
+	legacy := `{"from":"0xd639a62be604374ff04af4112a555890bd822a03","to":"0x97549e368acafdcae786bb93d98379f1d1561a29","value":"0x14d1120d7b160000","hash":"` + hash.String() + `","data":"0x","blockNumber":null}`
+	event, err := decodeWatchEvent(topic, []byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Stage != notification.StageBroadcast || event.Hash != hash || event.Value != "1.5" || event.To != to.String() {
+		t.Errorf("legacy event mismatch: %+v", event)
+	}
+	if event.reached(notification.StageConfirmed) || !event.reached(notification.StageReceived) {
+		t.Errorf("legacy event stage %s reached mismatch", event.Stage)
+	}
+
+	payload, err := notification.Encode(&notification.Envelope{
+		Version:   notification.Version,
+		Type:      notification.TypeTransfer,
+		Stage:     notification.StageConfirmed,
+		Timestamp: 1593590400,
+		ChainID:   1007,
+		Tx: notification.Tx{
+			Hash:  hash,
+			To:    &to,
+			Value: (*hexutil.Big)(new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)),
+		},
+		Receipt: &notification.Receipt{Status: 1, BlockNumber: 100},
+	}, notification.EncodingCBOR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err = decodeWatchEvent(topic, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Stage != notification.StageConfirmed || event.Value != "100" || event.BlockNumber != 100 || event.Status != "success" {
+		t.Errorf("envelope event mismatch: %+v", event)
+	}
+	if !event.reached(notification.StageConfirmed) {
+		t.Errorf("envelope event stage %s reached mismatch", event.Stage)
+	}
+}
@@ -6,23 +6,30 @@ require (
 	github.com/allegro/bigcache v1.2.1 // indirect
 	github.com/aristanetworks/goarista v0.0.0-20200609010056-95bcf8053598 // indirect
 	github.com/btcsuite/btcutil v1.0.2
+	github.com/davecgh/go-spew v1.1.1
 	github.com/deckarep/golang-set v1.7.1
 	github.com/eclipse/paho.mqtt.golang v1.2.0
 	github.com/ethereum/go-ethereum v1.8.26
+	github.com/fxamacker/cbor/v2 v2.2.0
+	github.com/gofrs/flock v0.7.1
+	github.com/hashicorp/golang-lru v0.5.4
 	github.com/karalabe/hid v1.0.0 // indirect
 	github.com/pborman/uuid v1.2.0 // indirect
 	github.com/peterh/liner v1.2.0 // indirect
+	github.com/prometheus/client_golang v1.7.1
 	github.com/rjeczalik/notify v0.9.2 // indirect
 	github.com/robertkrimen/otto v0.0.0-20191219234010-c382bd3c16ff // indirect
 	github.com/rs/cors v1.7.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.0
-	github.com/syndtr/goleveldb v1.0.0 // indirect
+	github.com/syndtr/goleveldb v1.0.0
+	github.com/tyler-smith/go-bip39 v1.0.2
 	golang.org/x/net v0.0.0-20200707034311-ab3426394381
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	google.golang.org/grpc v1.30.0
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )
 
 replace github.com/ethereum/go-ethereum => github.com/newtonproject/newchain v1.8.26-newton-1.1
-
@@ -0,0 +1,436 @@
+// Package hdwallet derives the NewChain accounts from the BIP-39 mnemonic by the BIP-44 paths,
+// and the watch-only addresses from the extended public key.
+//
+// The keys of NewChain are on the NIST P-256 curve, so the keys are derived as SLIP-0010,
+// the BIP-32 derivation for the nist256p1 curve, and serialized as the BIP-32 xprv/xpub.
+package hdwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// CoinType is the SLIP-44 coin type of NewChain
+const CoinType = 1642
+
+const (
+	// DefaultAccountPath is the BIP-44 path of the first account, its xpub derives the watch-only addresses
+	DefaultAccountPath = "m/44'/1642'/0'"
+	// DefaultChainPath is the external chain of the first account, the addresses are <DefaultChainPath>/<index>
+	DefaultChainPath = DefaultAccountPath + "/0"
+
+	// HardenedKeyStart is the index of the first hardened child
+	HardenedKeyStart = 0x80000000
+)
+
+// masterKey is the HMAC key of the master key of SLIP-0010 for the nist256p1 curve
+var masterKey = []byte("Nist256p1 seed")
+
+var (
+	xprvVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+	xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+)
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrInvalidKey      = errors.New("invalid extended key")
+	ErrHardenedPublic  = errors.New("cannot derive a hardened child from the public key")
+	ErrDeriveTooDeep   = errors.New("cannot derive beyond depth 255")
+)
+
+// NewMnemonic returns a random mnemonic of 12, 15, 18, 21 or 24 words
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("invalid number of words %d, want 12, 15, 18, 21 or 24", words)
+	}
+
+	entropy, err := bip39.NewEntropy(words / 3 * 32)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// ParsePath parses the derivation path like m/44'/1642'/0'/0/1, the m/ is optional for the relative path
+func ParsePath(path string) ([]uint32, error) {
+	path = strings.TrimSpace(path)
+	if path == "m" || path == "" {
+		return nil, nil
+	}
+	path = strings.TrimPrefix(path, "m/")
+
+	var indexes []uint32
+	for _, component := range strings.Split(path, "/") {
+		hardened := strings.HasSuffix(component, "'") || strings.HasSuffix(component, "H") || strings.HasSuffix(component, "h")
+		if hardened {
+			component = component[:len(component)-1]
+		}
+		index, err := strconv.ParseUint(component, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path component %q", component)
+		}
+		if hardened {
+			index += HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(index))
+	}
+
+	return indexes, nil
+}
+
+// Key is the extended private or public key
+type Key struct {
+	key         []byte // the 32 bytes private key, or the 33 bytes compressed public key
+	chainCode   []byte
+	depth       uint8
+	fingerprint []byte // of the parent key
+	index       uint32
+	private     bool
+}
+
+// Wallet is the master key of the HD wallet
+type Wallet struct {
+	master *Key
+}
+
+// NewFromMnemonic returns the wallet of the mnemonic and the optional BIP-39 passphrase
+func NewFromMnemonic(mnemonic, passphrase string) (*Wallet, error) {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+
+	return NewFromSeed(bip39.NewSeed(mnemonic, passphrase))
+}
+
+// NewFromSeed returns the wallet of the BIP-32 seed
+func NewFromSeed(seed []byte) (*Wallet, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("invalid seed length")
+	}
+
+	n := crypto.S256().Params().N
+	I := hmacSha512(masterKey, seed)
+	for {
+		k := new(big.Int).SetBytes(I[:32])
+		if k.Sign() != 0 && k.Cmp(n) < 0 {
+			break
+		}
+		// SLIP-0010 retries with the digest as the seed
+		I = hmacSha512(masterKey, I)
+	}
+
+	master := &Key{
+		key:         I[:32],
+		chainCode:   I[32:],
+		fingerprint: make([]byte, 4),
+		private:     true,
+	}
+	return &Wallet{master: master}, nil
+}
+
+// Derive returns the extended private key at the path
+func (w *Wallet) Derive(path string) (*Key, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.master.derive(indexes)
+}
+
+// PrivateKey returns the private key at the path
+func (w *Wallet) PrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	key, err := w.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.PrivateKey()
+}
+
+// Address returns the address at the path
+func (w *Wallet) Address(path string) (common.Address, error) {
+	key, err := w.Derive(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return key.Address()
+}
+
+// XPub returns the extended public key at the path, the watch-only addresses are derived from it
+func (w *Wallet) XPub(path string) (string, error) {
+	key, err := w.Derive(path)
+	if err != nil {
+		return "", err
+	}
+
+	return key.Neuter().String(), nil
+}
+
+// DeriveAddress returns the address at the path relative to the extended key,
+// only the non-hardened path can be derived from the xpub
+func DeriveAddress(xkey, path string) (common.Address, error) {
+	key, err := ParseKey(xkey)
+	if err != nil {
+		return common.Address{}, err
+	}
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	child, err := key.derive(indexes)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return child.Address()
+}
+
+// ParseKey parses the serialized xprv or xpub
+func ParseKey(s string) (*Key, error) {
+	decoded := base58.Decode(s)
+	if len(decoded) != 82 {
+		return nil, ErrInvalidKey
+	}
+	payload, checksum := decoded[:78], decoded[78:]
+	if !bytes.Equal(doubleSha256(payload)[:4], checksum) {
+		return nil, ErrInvalidKey
+	}
+
+	key := &Key{
+		depth:       payload[4],
+		fingerprint: payload[5:9],
+		index:       binary.BigEndian.Uint32(payload[9:13]),
+		chainCode:   payload[13:45],
+	}
+	switch version := payload[:4]; {
+	case bytes.Equal(version, xprvVersion):
+		if payload[45] != 0 {
+			return nil, ErrInvalidKey
+		}
+		key.key = payload[46:]
+		key.private = true
+		if _, err := crypto.ToECDSA(key.key); err != nil {
+			return nil, ErrInvalidKey
+		}
+	case bytes.Equal(version, xpubVersion):
+		key.key = payload[45:]
+		if _, _, err := decompress(key.key); err != nil {
+			return nil, ErrInvalidKey
+		}
+	default:
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// IsPrivate returns true for the extended private key
+func (k *Key) IsPrivate() bool {
+	return k.private
+}
+
+// Neuter returns the extended public key of k
+func (k *Key) Neuter() *Key {
+	if !k.private {
+		return k
+	}
+
+	return &Key{
+		key:         k.publicKey(),
+		chainCode:   k.chainCode,
+		depth:       k.depth,
+		fingerprint: k.fingerprint,
+		index:       k.index,
+	}
+}
+
+// PrivateKey returns the private key of the extended private key
+func (k *Key) PrivateKey() (*ecdsa.PrivateKey, error) {
+	if !k.private {
+		return nil, errors.New("not private key")
+	}
+
+	return crypto.ToECDSA(k.key)
+}
+
+// Address returns the address of the key
+func (k *Key) Address() (common.Address, error) {
+	x, y, err := decompress(k.publicKey())
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(ecdsa.PublicKey{Curve: crypto.S256(), X: x, Y: y}), nil
+}
+
+// String returns the serialized xprv or xpub
+func (k *Key) String() string {
+	var payload bytes.Buffer
+	if k.private {
+		payload.Write(xprvVersion)
+	} else {
+		payload.Write(xpubVersion)
+	}
+	payload.WriteByte(k.depth)
+	payload.Write(k.fingerprint)
+	payload.Write(uint32Bytes(k.index))
+	payload.Write(k.chainCode)
+	if k.private {
+		payload.WriteByte(0)
+	}
+	payload.Write(k.key)
+
+	checksum := doubleSha256(payload.Bytes())[:4]
+	return base58.Encode(append(payload.Bytes(), checksum...))
+}
+
+func (k *Key) derive(indexes []uint32) (*Key, error) {
+	var err error
+	for _, index := range indexes {
+		if k, err = k.child(index); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// child derives the child key as SLIP-0010, the invalid key is retried from the digest instead of skipped
+func (k *Key) child(index uint32) (*Key, error) {
+	if k.depth == 255 {
+		return nil, ErrDeriveTooDeep
+	}
+	hardened := index >= HardenedKeyStart
+	if hardened && !k.private {
+		return nil, ErrHardenedPublic
+	}
+
+	var data []byte
+	if hardened {
+		data = append([]byte{0}, k.key...)
+	} else {
+		data = k.publicKey()
+	}
+	data = append(data, uint32Bytes(index)...)
+
+	curve := crypto.S256()
+	n := curve.Params().N
+	for {
+		I := hmacSha512(k.chainCode, data)
+		IL, IR := new(big.Int).SetBytes(I[:32]), I[32:]
+
+		child := &Key{
+			chainCode:   IR,
+			depth:       k.depth + 1,
+			fingerprint: btcutil.Hash160(k.publicKey())[:4],
+			index:       index,
+			private:     k.private,
+		}
+		if IL.Cmp(n) < 0 {
+			if k.private {
+				key := new(big.Int).Add(IL, new(big.Int).SetBytes(k.key))
+				key.Mod(key, n)
+				if key.Sign() != 0 {
+					child.key = math.PaddedBigBytes(key, 32)
+					return child, nil
+				}
+			} else {
+				x, y, err := decompress(k.key)
+				if err != nil {
+					return nil, err
+				}
+				ilx, ily := curve.ScalarBaseMult(I[:32])
+				x, y = curve.Add(ilx, ily, x, y)
+				if x.Sign() != 0 || y.Sign() != 0 {
+					child.key = compress(x, y)
+					return child, nil
+				}
+			}
+		}
+
+		data = append([]byte{1}, IR...)
+		data = append(data, uint32Bytes(index)...)
+	}
+}
+
+// publicKey returns the compressed public key
+func (k *Key) publicKey() []byte {
+	if !k.private {
+		return k.key
+	}
+
+	return compress(crypto.S256().ScalarBaseMult(k.key))
+}
+
+func compress(x, y *big.Int) []byte {
+	return append([]byte{byte(2 + y.Bit(0))}, math.PaddedBigBytes(x, 32)...)
+}
+
+// decompress returns the point of the compressed public key, y² = x³ - 3x + b and p ≡ 3 mod 4
+func decompress(key []byte) (*big.Int, *big.Int, error) {
+	if len(key) != 33 || (key[0] != 2 && key[0] != 3) {
+		return nil, nil, ErrInvalidKey
+	}
+
+	params := crypto.S256().Params()
+	p := params.P
+	x := new(big.Int).SetBytes(key[1:])
+	if x.Cmp(p) >= 0 {
+		return nil, nil, ErrInvalidKey
+	}
+
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	y2.Sub(y2, new(big.Int).Mul(x, big.NewInt(3)))
+	y2.Add(y2, params.B)
+	y2.Mod(y2, p)
+
+	exp := new(big.Int).Add(p, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(y2, exp, p)
+	if y.Bit(0) != uint(key[0]&1) {
+		y.Sub(p, y)
+	}
+	if !crypto.S256().IsOnCurve(x, y) {
+		return nil, nil, ErrInvalidKey
+	}
+
+	return x, y, nil
+}
+
+func hmacSha512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func doubleSha256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func uint32Bytes(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
@@ -0,0 +1,108 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// SLIP-0010 test vectors for nist256p1, the last two derive with the retry
+func TestDeriveVectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	w, err := NewFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, chainCode, key string
+	}{
+		{"m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"m/0H", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"m/0H/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{"m/28578H", "e94c8ebe30c2250a14713212f6449b20f3329105ea15b652ca5bdfc68f6c65c2", "06f0db126f023755d0b8d86d4591718a5210dd8d024e3e14b6159d63f53aa669"},
+		{"m/28578H/33941", "9e87fe95031f14736774cd82f25fd885065cb7c358c1edf813c72af535e83071", "092154eed4af83e078ff9b84322015aefe5769e31270f62c3f66c33888335f3a"},
+	}
+	for _, test := range tests {
+		key, err := w.Derive(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chainCode := hex.EncodeToString(key.chainCode); chainCode != test.chainCode {
+			t.Errorf("%s: chain code = %s, want %s", test.path, chainCode, test.chainCode)
+		}
+		if k := hex.EncodeToString(key.key); k != test.key {
+			t.Errorf("%s: key = %s, want %s", test.path, k, test.key)
+		}
+
+		parsed, err := ParseKey(key.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.String() != key.String() {
+			t.Errorf("%s: parsed %s, want %s", test.path, parsed.String(), key.String())
+		}
+	}
+}
+
+func TestDeriveAddress(t *testing.T) {
+	w, err := NewFromMnemonic(testMnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the watch-only addresses from the xpub are the addresses of the private keys
+	xpub, err := w.XPub(DefaultAccountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(xpub, "xpub") {
+		t.Fatalf("xpub = %s", xpub)
+	}
+	for _, index := range []string{"0", "1", "1000"} {
+		key, err := w.PrivateKey(DefaultChainPath + "/" + index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watched, err := DeriveAddress(xpub, "0/"+index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := crypto.PubkeyToAddress(key.PublicKey); watched != want {
+			t.Errorf("index %s: watch-only address %s, want %s", index, watched.String(), want.String())
+		}
+	}
+	if _, err := DeriveAddress(xpub, "0'/0"); err != ErrHardenedPublic {
+		t.Errorf("err = %v, want hardened public", err)
+	}
+
+	if _, err := NewFromMnemonic("abandon abandon", ""); err != ErrInvalidMnemonic {
+		t.Errorf("err = %v, want invalid mnemonic", err)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	indexes, err := ParsePath(DefaultChainPath + "/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{0x8000002c, 0x8000066a, 0x80000000, 0, 5}
+	if len(indexes) != len(want) {
+		t.Fatalf("indexes = %v, want %v", indexes, want)
+	}
+	for i := range want {
+		if indexes[i] != want[i] {
+			t.Fatalf("indexes = %v, want %v", indexes, want)
+		}
+	}
+
+	for _, path := range []string{"m/x", "m/44'/-1", "m/2147483648"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("path %s accepted", path)
+		}
+	}
+}
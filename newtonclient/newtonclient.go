@@ -3,8 +3,12 @@ package newtonclient
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -40,19 +44,31 @@ func (ec *Client) Close() {
 }
 
 type BaseInfo struct {
-	GasPrice     *big.Int `json:"gasPrice"`
-	NetworkID    uint64   `json:"networkID"`
-	NonceLatest  uint64   `json:"nonceLatest"`
-	NoncePending uint64   `json:"noncePending"`
-	Balance      *big.Int `json:"balance"`
+	GasPrice     *big.Int    `json:"gasPrice"`
+	NetworkID    uint64      `json:"networkID"`
+	NonceLatest  uint64      `json:"nonceLatest"`
+	NoncePending uint64      `json:"noncePending"`
+	Balance      *big.Int    `json:"balance"`
+	BlockNumber  *big.Int    `json:"blockNumber"`
+	BlockHash    common.Hash `json:"blockHash"`
 }
 
-// NetworkID returns the network ID (also known as the chain ID) for this chain.
+// GetBaseInfo returns the base info of the account at the latest block.
 func (ec *Client) GetBaseInfo(ctx context.Context, account common.Address) (*BaseInfo, error) {
+	return ec.GetBaseInfoAt(ctx, account, nil, nil)
+}
+
+// GetBaseInfoAt returns the base info of the account at the block specified by number or hash.
+// If both number and hash are nil, the latest block is used.
+func (ec *Client) GetBaseInfoAt(ctx context.Context, account common.Address, number *big.Int, hash *common.Hash) (*BaseInfo, error) {
 	var args = struct {
-		Address common.Address `json:"address"`
+		Address     common.Address `json:"address"`
+		BlockNumber *hexutil.Big   `json:"blockNumber,omitempty"`
+		BlockHash   *common.Hash   `json:"blockHash,omitempty"`
 	}{
-		Address: account,
+		Address:     account,
+		BlockNumber: (*hexutil.Big)(number),
+		BlockHash:   hash,
 	}
 
 	var info struct {
@@ -61,6 +77,8 @@ func (ec *Client) GetBaseInfo(ctx context.Context, account common.Address) (*Bas
 		GasPrice     *hexutil.Big    `json:"gasPrice"`
 		NetworkID    uint64          `json:"networkID"`
 		Balance      *hexutil.Big    `json:"balance"`
+		BlockNumber  *hexutil.Big    `json:"blockNumber"`
+		BlockHash    common.Hash     `json:"blockHash"`
 	}
 	if err := ec.c.CallObjectContext(ctx, &info, "newton_getBaseInfo", args); err != nil {
 		return nil, err
@@ -87,29 +105,44 @@ func (ec *Client) GetBaseInfo(ctx context.Context, account common.Address) (*Bas
 		balance = balance.Set(info.Balance.ToInt())
 	}
 
+	var blockNumber *big.Int
+	if info.BlockNumber != nil {
+		blockNumber = info.BlockNumber.ToInt()
+	}
+
 	return &BaseInfo{
 		NonceLatest:  nonceLatest,
 		NoncePending: noncePending,
 		GasPrice:     gasPrice,
 		NetworkID:    networkID,
 		Balance:      balance,
+		BlockNumber:  blockNumber,
+		BlockHash:    info.BlockHash,
 	}, nil
 }
 
 // SendTransaction injects a signed transaction into the pending pool for execution.
 func (ec *Client) SendTransaction(ctx context.Context, rlpTx, signature []byte, from common.Address, wait uint64) (common.Hash, error) {
+	return ec.SendTransactionWithKey(ctx, rlpTx, signature, from, wait, "")
+}
+
+// SendTransactionWithKey injects a signed transaction into the pending pool for execution.
+// The retry with the same idempotency key returns the hash of the original submission.
+func (ec *Client) SendTransactionWithKey(ctx context.Context, rlpTx, signature []byte, from common.Address, wait uint64, key string) (common.Hash, error) {
 	var hash common.Hash
 
 	var tx = struct {
-		From      common.Address `json:"from"`
-		RlpTx     hexutil.Bytes  `json:"tx"`
-		Signature hexutil.Bytes  `json:"signature"`
-		Wait      uint64         `json:"wait"`
+		From           common.Address `json:"from"`
+		RlpTx          hexutil.Bytes  `json:"tx"`
+		Signature      hexutil.Bytes  `json:"signature"`
+		Wait           uint64         `json:"wait"`
+		IdempotencyKey string         `json:"idempotencyKey,omitempty"`
 	}{
-		From:      from,
-		RlpTx:     rlpTx,
-		Signature: signature,
-		Wait:      wait,
+		From:           from,
+		RlpTx:          rlpTx,
+		Signature:      signature,
+		Wait:           wait,
+		IdempotencyKey: key,
 	}
 
 	err := ec.c.CallObjectContext(ctx, &hash, "newton_sendTransaction", tx)
@@ -121,6 +154,12 @@ func (ec *Client) SendTransaction(ctx context.Context, rlpTx, signature []byte,
 
 // SendRawTransaction injects a signed transaction into the pending pool for execution.
 func (ec *Client) SendRawTransaction(ctx context.Context, tx *types.Transaction, wait uint64) (common.Hash, error) {
+	return ec.SendRawTransactionWithKey(ctx, tx, wait, "")
+}
+
+// SendRawTransactionWithKey injects a signed transaction into the pending pool for execution.
+// The retry with the same idempotency key returns the hash of the original submission.
+func (ec *Client) SendRawTransactionWithKey(ctx context.Context, tx *types.Transaction, wait uint64, key string) (common.Hash, error) {
 	var hash common.Hash
 
 	data, err := rlp.EncodeToBytes(tx)
@@ -129,11 +168,13 @@ func (ec *Client) SendRawTransaction(ctx context.Context, tx *types.Transaction,
 	}
 
 	var sendTx = struct {
-		Tx   hexutil.Bytes `json:"tx"`
-		Wait uint64        `json:"wait"`
+		Tx             hexutil.Bytes `json:"tx"`
+		Wait           uint64        `json:"wait"`
+		IdempotencyKey string        `json:"idempotencyKey,omitempty"`
 	}{
-		Tx:   data,
-		Wait: wait,
+		Tx:             data,
+		Wait:           wait,
+		IdempotencyKey: key,
 	}
 
 	err = ec.c.CallObjectContext(ctx, &hash, "newton_sendRawTransaction", sendTx)
@@ -143,3 +184,531 @@ func (ec *Client) SendRawTransaction(ctx context.Context, tx *types.Transaction,
 
 	return hash, nil
 }
+
+// UnsignedTx is the unsigned transaction built by the server
+type UnsignedTx struct {
+	Tx        []byte      `json:"tx"`
+	Hash      common.Hash `json:"hash"`
+	Nonce     uint64      `json:"nonce"`
+	GasPrice  *big.Int    `json:"gasPrice"`
+	Gas       uint64      `json:"gas"`
+	NetworkID uint64      `json:"networkID"`
+}
+
+// BuildTransaction builds an unsigned transaction on the server with nonce, gas price,
+// gas limit and chain ID filled. If gas is 0, the server estimates the gas limit.
+func (ec *Client) BuildTransaction(ctx context.Context, from common.Address, to *common.Address, value *big.Int, data []byte, gas uint64) (*UnsignedTx, error) {
+	var args = struct {
+		From  common.Address  `json:"from"`
+		To    *common.Address `json:"to"`
+		Value *hexutil.Big    `json:"value"`
+		Data  hexutil.Bytes   `json:"data,omitempty"`
+		Gas   *hexutil.Uint64 `json:"gas,omitempty"`
+	}{
+		From:  from,
+		To:    to,
+		Value: (*hexutil.Big)(value),
+		Data:  data,
+	}
+	if gas > 0 {
+		args.Gas = (*hexutil.Uint64)(&gas)
+	}
+
+	var tx struct {
+		Tx        hexutil.Bytes  `json:"tx"`
+		Hash      common.Hash    `json:"hash"`
+		Nonce     hexutil.Uint64 `json:"nonce"`
+		GasPrice  *hexutil.Big   `json:"gasPrice"`
+		Gas       hexutil.Uint64 `json:"gas"`
+		NetworkID uint64         `json:"networkID"`
+	}
+	if err := ec.c.CallObjectContext(ctx, &tx, "newton_buildTransaction", args); err != nil {
+		return nil, err
+	}
+
+	gasPrice := big.NewInt(0)
+	if tx.GasPrice != nil {
+		gasPrice = gasPrice.Set(tx.GasPrice.ToInt())
+	}
+
+	return &UnsignedTx{
+		Tx:        tx.Tx,
+		Hash:      tx.Hash,
+		Nonce:     uint64(tx.Nonce),
+		GasPrice:  gasPrice,
+		Gas:       uint64(tx.Gas),
+		NetworkID: tx.NetworkID,
+	}, nil
+}
+
+// SendTxResult is the result of one transaction in a batch submission
+type SendTxResult struct {
+	Hash  common.Hash
+	Error error
+}
+
+// SignedTx is the unsigned raw transaction with signature
+type SignedTx struct {
+	From      common.Address
+	RlpTx     []byte
+	Signature []byte
+}
+
+// SendRawTransactions injects the signed transactions into the pending pool in batch.
+// The returned results are in the order of txs.
+func (ec *Client) SendRawTransactions(ctx context.Context, txs []*types.Transaction, wait uint64) ([]*SendTxResult, error) {
+	type sendTx struct {
+		Tx   hexutil.Bytes `json:"tx"`
+		Wait uint64        `json:"wait"`
+	}
+
+	args := make([]sendTx, len(txs))
+	for i, tx := range txs {
+		data, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = sendTx{Tx: data, Wait: wait}
+	}
+
+	return ec.sendBatch(ctx, "newton_sendRawTransactions", args, len(args))
+}
+
+// SendTransactions injects the transactions with signature into the pending pool in batch.
+// The returned results are in the order of txs.
+func (ec *Client) SendTransactions(ctx context.Context, txs []*SignedTx, wait uint64) ([]*SendTxResult, error) {
+	type sendTx struct {
+		From      common.Address `json:"from"`
+		RlpTx     hexutil.Bytes  `json:"tx"`
+		Signature hexutil.Bytes  `json:"signature"`
+		Wait      uint64         `json:"wait"`
+	}
+
+	args := make([]sendTx, len(txs))
+	for i, tx := range txs {
+		args[i] = sendTx{From: tx.From, RlpTx: tx.RlpTx, Signature: tx.Signature, Wait: wait}
+	}
+
+	return ec.sendBatch(ctx, "newton_sendTransactions", args, len(args))
+}
+
+func (ec *Client) sendBatch(ctx context.Context, method string, args interface{}, n int) ([]*SendTxResult, error) {
+	var raw []struct {
+		Hash  *common.Hash `json:"hash"`
+		Error string       `json:"error"`
+	}
+
+	batch := []rpc.BatchElem{{Method: method, Args: []interface{}{args}, Result: &raw}}
+	if err := ec.c.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+	if batch[0].Error != nil {
+		return nil, batch[0].Error
+	}
+	if len(raw) != n {
+		return nil, fmt.Errorf("batch result length mismatch, want %d, got %d", n, len(raw))
+	}
+
+	results := make([]*SendTxResult, n)
+	for i, r := range raw {
+		results[i] = &SendTxResult{}
+		if r.Error != "" {
+			results[i].Error = errors.New(r.Error)
+		}
+		if r.Hash != nil {
+			results[i].Hash = *r.Hash
+		}
+	}
+
+	return results, nil
+}
+
+// Submission is the tracked state of the submitted tx
+type Submission struct {
+	Hash           common.Hash     `json:"hash"`
+	From           common.Address  `json:"from"`
+	To             *common.Address `json:"to"`
+	FromNEW        string          `json:"fromNEW"` // set if the NEW address is enabled on the server
+	ToNEW          string          `json:"toNEW"`
+	Nonce          uint64          `json:"nonce"`
+	Status         string          `json:"status"`
+	Error          string          `json:"error"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	UpdatedAt      int64           `json:"updatedAt"`
+}
+
+// GetSubmission returns the tracked state of the submitted tx by hash or idempotency key
+func (ec *Client) GetSubmission(ctx context.Context, hash *common.Hash, key string) (*Submission, error) {
+	var args = struct {
+		Hash           *common.Hash `json:"hash,omitempty"`
+		IdempotencyKey string       `json:"idempotencyKey,omitempty"`
+	}{
+		Hash:           hash,
+		IdempotencyKey: key,
+	}
+
+	var submission Submission
+	if err := ec.c.CallObjectContext(ctx, &submission, "newton_getSubmission", args); err != nil {
+		return nil, err
+	}
+
+	return &submission, nil
+}
+
+// AccountInfo is the base info of one address
+type AccountInfo struct {
+	Address common.Address
+	*BaseInfo
+	Error error
+}
+
+// GetBaseInfos returns the base info of the addresses at latest block.
+// The result is in the order of accounts, the error of each address is set in AccountInfo.
+func (ec *Client) GetBaseInfos(ctx context.Context, accounts []common.Address) ([]*AccountInfo, error) {
+	var args = struct {
+		Addresses []common.Address `json:"addresses"`
+	}{
+		Addresses: accounts,
+	}
+
+	var infos struct {
+		GasPrice    *hexutil.Big `json:"gasPrice"`
+		NetworkID   uint64       `json:"networkID"`
+		BlockNumber *hexutil.Big `json:"blockNumber"`
+		BlockHash   common.Hash  `json:"blockHash"`
+		Infos       []struct {
+			Address      common.Address  `json:"address"`
+			NonceLatest  *hexutil.Uint64 `json:"nonceLatest"`
+			NoncePending *hexutil.Uint64 `json:"noncePending"`
+			Balance      *hexutil.Big    `json:"balance"`
+			Error        string          `json:"error"`
+		} `json:"infos"`
+	}
+	if err := ec.c.CallObjectContext(ctx, &infos, "newton_getBaseInfos", args); err != nil {
+		return nil, err
+	}
+	if len(infos.Infos) != len(accounts) {
+		return nil, fmt.Errorf("base infos length mismatch, want %d, got %d", len(accounts), len(infos.Infos))
+	}
+
+	gasPrice := big.NewInt(1)
+	if infos.GasPrice != nil {
+		gasPrice = gasPrice.Set(infos.GasPrice.ToInt())
+	}
+
+	result := make([]*AccountInfo, len(infos.Infos))
+	for i, info := range infos.Infos {
+		if info.Error != "" {
+			result[i] = &AccountInfo{Address: info.Address, Error: errors.New(info.Error)}
+			continue
+		}
+
+		baseInfo := &BaseInfo{
+			GasPrice:    gasPrice,
+			NetworkID:   infos.NetworkID,
+			Balance:     big.NewInt(0),
+			BlockNumber: (*big.Int)(infos.BlockNumber),
+			BlockHash:   infos.BlockHash,
+		}
+		if info.NonceLatest != nil {
+			baseInfo.NonceLatest = uint64(*info.NonceLatest)
+		}
+		if info.NoncePending != nil {
+			baseInfo.NoncePending = uint64(*info.NoncePending)
+		}
+		if info.Balance != nil {
+			baseInfo.Balance.Set(info.Balance.ToInt())
+		}
+
+		result[i] = &AccountInfo{Address: info.Address, BaseInfo: baseInfo}
+	}
+
+	return result, nil
+}
+
+// HistoryTx is a native transfer or a token Transfer log of the address
+type HistoryTx struct {
+	Hash        common.Hash     `json:"hash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Timestamp   hexutil.Uint64  `json:"timestamp"`
+	TxIndex     hexutil.Uint    `json:"transactionIndex"`
+	From        common.Address  `json:"from"`
+	To          *common.Address `json:"to"`
+	FromNEW     string          `json:"fromNEW"` // set if the NEW address is enabled on the server
+	ToNEW       string          `json:"toNEW"`
+	Value       *hexutil.Big    `json:"value"`
+	Token       *common.Address `json:"token"`
+	LogIndex    *hexutil.Uint   `json:"logIndex"`
+	Direction   string          `json:"direction"`
+}
+
+// TransactionsPage is a page of the transaction history of the address
+type TransactionsPage struct {
+	Transactions  []*HistoryTx   `json:"transactions"`
+	NextCursor    hexutil.Bytes  `json:"nextCursor"`
+	IndexedHeight hexutil.Uint64 `json:"indexedHeight"`
+}
+
+// GetTransactions returns a page of the transaction history of the address.
+// Pass the NextCursor of the previous page as cursor to get the next page.
+func (ec *Client) GetTransactions(ctx context.Context, account common.Address, cursor []byte, limit uint64, direction, order string) (*TransactionsPage, error) {
+	var args = struct {
+		Address   common.Address `json:"address"`
+		Cursor    hexutil.Bytes  `json:"cursor,omitempty"`
+		Limit     uint64         `json:"limit,omitempty"`
+		Direction string         `json:"direction,omitempty"`
+		Order     string         `json:"order,omitempty"`
+	}{
+		Address:   account,
+		Cursor:    cursor,
+		Limit:     limit,
+		Direction: direction,
+		Order:     order,
+	}
+
+	var page TransactionsPage
+	if err := ec.c.CallObjectContext(ctx, &page, "newton_getTransactions", args); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// WatchAddress adds the address to the watched addresses of the server,
+// returns false if the address is already watched
+func (ec *Client) WatchAddress(ctx context.Context, account common.Address) (bool, error) {
+	return ec.callWatch(ctx, "newton_watchAddress", account)
+}
+
+// UnwatchAddress removes the address from the watched addresses of the server,
+// returns false if the address is not watched
+func (ec *Client) UnwatchAddress(ctx context.Context, account common.Address) (bool, error) {
+	return ec.callWatch(ctx, "newton_unwatchAddress", account)
+}
+
+func (ec *Client) callWatch(ctx context.Context, method string, account common.Address) (bool, error) {
+	var args = struct {
+		Address common.Address `json:"address"`
+	}{
+		Address: account,
+	}
+
+	var changed bool
+	if err := ec.c.CallObjectContext(ctx, &changed, method, args); err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// WatchXPub watches the addresses <xpub>/0/<index> for the indexes in [start, start+count) on the server,
+// returns the derived addresses
+func (ec *Client) WatchXPub(ctx context.Context, xpub string, start, count uint64) ([]common.Address, error) {
+	var args = struct {
+		XPub  string `json:"xpub"`
+		Start uint64 `json:"start"`
+		Count uint64 `json:"count"`
+	}{
+		XPub:  xpub,
+		Start: start,
+		Count: count,
+	}
+
+	var addresses []common.Address
+	if err := ec.c.CallObjectContext(ctx, &addresses, "newton_watchXPub", args); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// SignatureResult is the signer recovered by newton_verifySignature
+type SignatureResult struct {
+	Signer    *common.Address `json:"signer"`
+	SignerNEW string          `json:"signerNEW,omitempty"`
+	Valid     bool            `json:"valid"`
+}
+
+// VerifySignature recovers the signer of the personal_sign message or the EIP-712 typed data JSON on the server,
+// the address is required for the 64 bytes signature
+func (ec *Client) VerifySignature(ctx context.Context, message *string, typedData json.RawMessage, signature []byte, address *common.Address) (*SignatureResult, error) {
+	var args = struct {
+		Message   *string         `json:"message,omitempty"`
+		TypedData json.RawMessage `json:"typedData,omitempty"`
+		Signature hexutil.Bytes   `json:"signature"`
+		Address   *common.Address `json:"address,omitempty"`
+	}{
+		Message:   message,
+		TypedData: typedData,
+		Signature: signature,
+		Address:   address,
+	}
+
+	var result SignatureResult
+	if err := ec.c.CallObjectContext(ctx, &result, "newton_verifySignature", args); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetWatchedAddresses returns the watched addresses of the server
+func (ec *Client) GetWatchedAddresses(ctx context.Context) ([]common.Address, error) {
+	var addresses []common.Address
+	if err := ec.c.CallArrayContext(ctx, &addresses, "newton_getWatchedAddresses"); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// Transaction is the transaction with the confirmations
+type Transaction struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	FromNEW          string          `json:"fromNEW"` // set if the NEW address is enabled on the server
+	ToNEW            string          `json:"toNEW"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	Value            *hexutil.Big    `json:"value"`
+	ValueNEW         string          `json:"valueNEW"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	Input            hexutil.Bytes   `json:"input"`
+	Pending          bool            `json:"pending"`
+	BlockNumber      *hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        *common.Hash    `json:"blockHash"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+	Confirmations    hexutil.Uint64  `json:"confirmations"`
+}
+
+// Receipt is the receipt with the status, fee and confirmations
+type Receipt struct {
+	Hash             common.Hash     `json:"hash"`
+	From             common.Address  `json:"from"`
+	To               *common.Address `json:"to"`
+	ContractAddress  *common.Address `json:"contractAddress"`
+	FromNEW          string          `json:"fromNEW"` // set if the NEW address is enabled on the server
+	ToNEW            string          `json:"toNEW"`
+	ContractNEW      string          `json:"contractAddressNEW"`
+	Status           string          `json:"status"`
+	BlockNumber      hexutil.Uint64  `json:"blockNumber"`
+	BlockHash        common.Hash     `json:"blockHash"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex"`
+	Value            *hexutil.Big    `json:"value"`
+	ValueNEW         string          `json:"valueNEW"`
+	GasUsed          hexutil.Uint64  `json:"gasUsed"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Fee              *hexutil.Big    `json:"fee"`
+	FeeNEW           string          `json:"feeNEW"`
+	Logs             hexutil.Uint    `json:"logs"`
+	Confirmations    hexutil.Uint64  `json:"confirmations"`
+}
+
+// GetTransaction returns the transaction by hash
+func (ec *Client) GetTransaction(ctx context.Context, hash common.Hash) (*Transaction, error) {
+	var tx Transaction
+	if err := ec.c.CallObjectContext(ctx, &tx, "newton_getTransaction", struct {
+		Hash common.Hash `json:"hash"`
+	}{hash}); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+// GetReceipt returns the receipt of the transaction by hash
+func (ec *Client) GetReceipt(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	var receipt Receipt
+	if err := ec.c.CallObjectContext(ctx, &receipt, "newton_getReceipt", struct {
+		Hash common.Hash `json:"hash"`
+	}{hash}); err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+// Status is the state of the server and its upstream node and notifier
+type Status struct {
+	Ready  bool     `json:"ready"`
+	Issues []string `json:"issues"`
+	Node   struct {
+		Reachable    bool            `json:"reachable"`
+		Error        string          `json:"error"`
+		Syncing      bool            `json:"syncing"`
+		CurrentBlock *hexutil.Uint64 `json:"currentBlock"`
+		HighestBlock *hexutil.Uint64 `json:"highestBlock"`
+		BlockNumber  *hexutil.Uint64 `json:"blockNumber"`
+		HeadAge      uint64          `json:"headAge"`
+	} `json:"node"`
+	Notifier struct {
+		Connected bool `json:"connected"`
+	} `json:"notifier"`
+	Queue struct {
+		Length               int `json:"length"`
+		Capacity             int `json:"capacity"`
+		PendingConfirmations int `json:"pendingConfirmations"`
+	} `json:"queue"`
+	GasPrice struct {
+		GasPrice *hexutil.Big `json:"gasPrice"`
+		Age      uint64       `json:"age"`
+	} `json:"gasPrice"`
+}
+
+// Status returns the state of the server
+func (ec *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := ec.c.CallArrayContext(ctx, &status, "newton_status"); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// CallContract executes the message call without creating a transaction by eth_call,
+// the proxy of the server must be enabled. If blockNumber is nil, the latest block is used.
+func (ec *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	block := "latest"
+	if blockNumber != nil {
+		block = hexutil.EncodeBig(blockNumber)
+	}
+
+	var result hexutil.Bytes
+	if err := ec.c.CallArrayContext(ctx, &result, "eth_call", toCallArg(msg), block); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// EstimateGas estimates the gas needed to execute the message by eth_estimateGas,
+// the proxy of the server must be enabled.
+func (ec *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas hexutil.Uint64
+	if err := ec.c.CallArrayContext(ctx, &gas, "eth_estimateGas", toCallArg(msg)); err != nil {
+		return 0, err
+	}
+
+	return uint64(gas), nil
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
@@ -0,0 +1,160 @@
+// Package notification defines the versioned notification published by the express server,
+// and provides the subscriber for the consumers.
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Version is the version of the envelope
+const Version = 1
+
+// event types
+const (
+	TypeTransfer         = "transfer" // the NEW transfer or the contract call
+	TypeContractCreation = "contractCreation"
+	TypeTokenTransfer    = "tokenTransfer" // the token Transfer event, the from, to and value are of the event
+)
+
+// stages of the tx
+const (
+	StageReceived  = "received"
+	StageBroadcast = "broadcast"
+	StageConfirmed = "confirmed"
+	StageFailed    = "failed"
+)
+
+// encodings of the payload
+const (
+	EncodingJSON = "json"
+	EncodingCBOR = "cbor"
+)
+
+// Envelope is the notification of a stage of the tx
+type Envelope struct {
+	Version   uint     `json:"version" cbor:"1,keyasint"`
+	Type      string   `json:"type" cbor:"2,keyasint"`
+	Stage     string   `json:"stage" cbor:"3,keyasint"`
+	Timestamp int64    `json:"timestamp" cbor:"4,keyasint"` // unix seconds when the stage is reached
+	ChainID   uint64   `json:"chainId" cbor:"5,keyasint"`
+	Tx        Tx       `json:"tx" cbor:"6,keyasint"`
+	Receipt   *Receipt `json:"receipt,omitempty" cbor:"7,keyasint,omitempty"` // set if confirmed
+	Error     string   `json:"error,omitempty" cbor:"8,keyasint,omitempty"`   // set if failed
+}
+
+// Tx is the fields of the tx, the NEW format addresses are set if enabled on the server
+type Tx struct {
+	Hash    common.Hash     `json:"hash"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Data    hexutil.Bytes   `json:"data"`
+	FromNEW string          `json:"fromNEW,omitempty"`
+	ToNEW   string          `json:"toNEW,omitempty"`
+	Token   *common.Address `json:"token,omitempty"` // the token contract of the tokenTransfer
+}
+
+// cborTx is the compact CBOR form of Tx, the value is the big-endian bytes
+type cborTx struct {
+	Hash  common.Hash     `cbor:"1,keyasint"`
+	From  common.Address  `cbor:"2,keyasint"`
+	To    *common.Address `cbor:"3,keyasint,omitempty"`
+	Value []byte          `cbor:"4,keyasint,omitempty"`
+	Data  []byte          `cbor:"5,keyasint,omitempty"`
+
+	FromNEW string          `cbor:"6,keyasint,omitempty"`
+	ToNEW   string          `cbor:"7,keyasint,omitempty"`
+	Token   *common.Address `cbor:"8,keyasint,omitempty"`
+}
+
+// MarshalCBOR encodes to the compact CBOR form.
+func (tx Tx) MarshalCBOR() ([]byte, error) {
+	enc := cborTx{
+		Hash: tx.Hash,
+		From: tx.From,
+		To:   tx.To,
+		Data: tx.Data,
+
+		FromNEW: tx.FromNEW,
+		ToNEW:   tx.ToNEW,
+		Token:   tx.Token,
+	}
+	if tx.Value != nil {
+		enc.Value = tx.Value.ToInt().Bytes()
+	}
+
+	return cbor.Marshal(enc)
+}
+
+// UnmarshalCBOR decodes from the compact CBOR form.
+func (tx *Tx) UnmarshalCBOR(data []byte) error {
+	var dec cborTx
+	if err := cbor.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+
+	tx.Hash = dec.Hash
+	tx.From = dec.From
+	tx.To = dec.To
+	tx.Value = (*hexutil.Big)(new(big.Int).SetBytes(dec.Value))
+	tx.Data = dec.Data
+	tx.FromNEW = dec.FromNEW
+	tx.ToNEW = dec.ToNEW
+	tx.Token = dec.Token
+
+	return nil
+}
+
+// Receipt is the fields of the receipt of the confirmed tx
+type Receipt struct {
+	Status           hexutil.Uint64  `json:"status" cbor:"1,keyasint"` // 1 for success, 0 for failed
+	BlockNumber      hexutil.Uint64  `json:"blockNumber" cbor:"2,keyasint"`
+	BlockHash        common.Hash     `json:"blockHash" cbor:"3,keyasint"`
+	TransactionIndex hexutil.Uint64  `json:"transactionIndex" cbor:"4,keyasint"`
+	GasUsed          hexutil.Uint64  `json:"gasUsed" cbor:"5,keyasint"`
+	ContractAddress  *common.Address `json:"contractAddress,omitempty" cbor:"6,keyasint,omitempty"`
+}
+
+// Encode encodes the envelope as json or cbor
+func Encode(e *Envelope, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON, "":
+		return json.Marshal(e)
+	case EncodingCBOR:
+		return cbor.Marshal(e)
+	default:
+		return nil, fmt.Errorf("unknown encoding %s", encoding)
+	}
+}
+
+// Decode decodes the envelope, the encoding is detected from the payload
+func Decode(data []byte) (*Envelope, error) {
+	e := new(Envelope)
+
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, e)
+	} else {
+		err = cbor.Unmarshal(data, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Version == 0 || e.Version > Version {
+		return nil, fmt.Errorf("unsupported notification version %d", e.Version)
+	}
+
+	return e, nil
+}
+
+// ValidEncoding returns true if the encoding is supported
+func ValidEncoding(encoding string) bool {
+	return encoding == EncodingJSON || encoding == EncodingCBOR
+}
@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	to := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+	e := &Envelope{
+		Version:   Version,
+		Type:      TypeTransfer,
+		Stage:     StageConfirmed,
+		Timestamp: 1593590400,
+		ChainID:   1007,
+		Tx: Tx{
+			Hash:  common.HexToHash("0x01"),
+			From:  common.HexToAddress("0xd639a62be604374ff04af4112a555890bd822a03"),
+			To:    &to,
+			Value: (*hexutil.Big)(new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)),
+			Data:  hexutil.Bytes{0xa9, 0x05, 0x9c, 0xbb},
+		},
+		Receipt: &Receipt{
+			Status:      1,
+			BlockNumber: 0x1a2b3c,
+			BlockHash:   common.HexToHash("0x02"),
+			GasUsed:     21000,
+		},
+	}
+
+	for _, encoding := range []string{EncodingJSON, EncodingCBOR} {
+		data, err := Encode(e, encoding)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(decoded, e) {
+			t.Errorf("%s: decoded %+v, want %+v", encoding, decoded, e)
+		}
+	}
+
+	// the token Transfer event
+	token := common.HexToAddress("0xd639a62be604374ff04af4112a555890bd822a03")
+	e.Type, e.Tx.Token, e.Tx.Data = TypeTokenTransfer, &token, nil
+	for _, encoding := range []string{EncodingJSON, EncodingCBOR} {
+		data, err := Encode(e, encoding)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if decoded.Type != TypeTokenTransfer || decoded.Tx.Token == nil || *decoded.Tx.Token != token {
+			t.Errorf("%s: decoded token transfer %+v, want token %s", encoding, decoded.Tx, token.String())
+		}
+	}
+
+	if _, err := Decode([]byte(`{"from":"0xd639a62be604374ff04af4112a555890bd822a03","value":"0x1"}`)); err == nil {
+		t.Error("decoded the unversioned payload")
+	}
+}
@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Handler handles the notification, err is set if the payload can not be decoded
+type Handler func(topic string, e *Envelope, err error)
+
+// Subscriber subscribes the notifications of the express server,
+// the subscriptions are restored after reconnected.
+type Subscriber struct {
+	client mqtt.Client
+	prefix string
+	qos    byte
+
+	lock          sync.Mutex
+	subscriptions map[string]mqtt.MessageHandler
+}
+
+// NewSubscriber creates the subscriber of the topics under prefix, e.g. newchain/api.
+// The OnConnect handler of opts is kept and called before resubscribing.
+func NewSubscriber(opts *mqtt.ClientOptions, prefix string, qos byte) *Subscriber {
+	s := &Subscriber{
+		prefix:        prefix,
+		qos:           qos,
+		subscriptions: make(map[string]mqtt.MessageHandler),
+	}
+
+	onConnect := opts.OnConnect
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		if onConnect != nil {
+			onConnect(c)
+		}
+		s.resubscribe()
+	})
+	s.client = mqtt.NewClient(opts)
+
+	return s
+}
+
+// Connect connects to the broker
+func (s *Subscriber) Connect() error {
+	token := s.client.Connect()
+	token.Wait()
+	return token.Error()
+}
+
+// Close unsubscribes all and disconnects
+func (s *Subscriber) Close() {
+	s.lock.Lock()
+	topics := make([]string, 0, len(s.subscriptions))
+	for topic := range s.subscriptions {
+		topics = append(topics, topic)
+	}
+	s.subscriptions = make(map[string]mqtt.MessageHandler)
+	s.lock.Unlock()
+
+	if len(topics) > 0 {
+		s.client.Unsubscribe(topics...).Wait()
+	}
+	s.client.Disconnect(250)
+}
+
+// SubscribeAddress subscribes the txs to the address, the empty stage is for all the stages
+func (s *Subscriber) SubscribeAddress(address common.Address, stage string, handler Handler) error {
+	return s.subscribe(AddressTopic(s.prefix, address, stage), handler)
+}
+
+// SubscribeContractCreation subscribes the contract creation txs
+func (s *Subscriber) SubscribeContractCreation(handler Handler) error {
+	return s.subscribe(ContractCreationTopic(s.prefix), handler)
+}
+
+// SubscribeTx subscribes the last status of the tx, the server must publish the retained status
+func (s *Subscriber) SubscribeTx(hash common.Hash, handler Handler) error {
+	return s.subscribe(TxTopic(s.prefix, hash), handler)
+}
+
+// Unsubscribe unsubscribes the topic
+func (s *Subscriber) Unsubscribe(topic string) error {
+	s.lock.Lock()
+	delete(s.subscriptions, topic)
+	s.lock.Unlock()
+
+	token := s.client.Unsubscribe(topic)
+	token.Wait()
+	return token.Error()
+}
+
+func (s *Subscriber) subscribe(topic string, handler Handler) error {
+	callback := func(c mqtt.Client, msg mqtt.Message) {
+		e, err := Decode(msg.Payload())
+		handler(msg.Topic(), e, err)
+	}
+
+	s.lock.Lock()
+	s.subscriptions[topic] = callback
+	s.lock.Unlock()
+
+	token := s.client.Subscribe(topic, s.qos, callback)
+	token.Wait()
+	return token.Error()
+}
+
+// resubscribe restores the subscriptions, the broker drops them if the session is clean
+func (s *Subscriber) resubscribe() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for topic, callback := range s.subscriptions {
+		s.client.Subscribe(topic, s.qos, callback)
+	}
+}
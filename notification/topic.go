@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// stageLevels are the levels of the stages in the address topics
+var stageLevels = map[string]int64{
+	StageReceived:  -1,
+	StageBroadcast: 0,
+	StageConfirmed: 1,
+}
+
+// StageLevel returns the level of the stage in the address topic, the failed stage has no level
+func StageLevel(stage string) (int64, bool) {
+	level, ok := stageLevels[stage]
+	return level, ok
+}
+
+// LevelStage returns the stage of the level in the address topic, e.g. the last level of newchain/api/<address>/0
+func LevelStage(level int64) (string, bool) {
+	for stage, l := range stageLevels {
+		if l == level {
+			return stage, true
+		}
+	}
+	return "", false
+}
+
+// AddressTopic returns <prefix>/<address>/<level> of the receiver, the address is lower case without 0x.
+// The empty stage returns the topic of all the stages.
+func AddressTopic(prefix string, address common.Address, stage string) string {
+	addr := strings.ToLower(address.Hex()[2:])
+	if stage == "" {
+		return fmt.Sprintf("%s/%s/+", prefix, addr)
+	}
+	return fmt.Sprintf("%s/%s/%d", prefix, addr, stageLevels[stage])
+}
+
+// ContractCreationTopic returns the topic of the contract creations
+func ContractCreationTopic(prefix string) string {
+	return fmt.Sprintf("%s/ContractCreate", prefix)
+}
+
+// TxTopic returns the topic of the retained last status of the tx
+func TxTopic(prefix string, hash common.Hash) string {
+	return fmt.Sprintf("%s/tx/%s", prefix, hash.String())
+}
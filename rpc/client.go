@@ -174,6 +174,8 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 		return DialWebsocket(ctx, rawurl, "")
 	case "stdio":
 		return DialStdIO(ctx)
+	case "":
+		return DialIPC(ctx, rawurl)
 	default:
 		return nil, fmt.Errorf("no known transport for URL scheme %q", u.Scheme)
 	}
@@ -240,7 +242,7 @@ func (c *Client) Close() {
 // can also pass nil, in which case the result is ignored.
 func (c *Client) Call(result interface{}, method string, args ...interface{}) error {
 	ctx := context.Background()
-	return c.CallArrayContext(ctx, result, method, args)
+	return c.CallArrayContext(ctx, result, method, args...)
 }
 
 // CallContext performs a JSON-RPC call with the given arguments. If the context is
@@ -248,6 +250,11 @@ func (c *Client) Call(result interface{}, method string, args ...interface{}) er
 //
 // The result must be a pointer so that package json can unmarshal into it. You
 // can also pass nil, in which case the result is ignored.
+func (c *Client) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return c.CallArrayContext(ctx, result, method, args...)
+}
+
+// CallArrayContext performs a JSON-RPC call with the given arguments as positional params.
 func (c *Client) CallArrayContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
 	msg, err := c.newArrayMessage(method, args...)
 	if err != nil {
@@ -398,7 +405,7 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 		return nil, ErrNotificationsUnsupported
 	}
 
-	msg, err := c.newArrayMessage(namespace+subscribeMethodSuffix, args)
+	msg, err := c.newArrayMessage(namespace+subscribeMethodSuffix, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -81,3 +81,9 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 	return listener, handler, err
 
 }
+
+// IPCListen creates the listener of the IPC endpoint, a unix socket path on supported platforms.
+// Use Server.ServeListener to serve on it.
+func IPCListen(endpoint string) (net.Listener, error) {
+	return ipcListen(endpoint)
+}
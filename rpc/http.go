@@ -199,8 +199,14 @@ func (t *httpReadWriteNopCloser) Close() error {
 //
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, vhosts []string, timeouts HTTPTimeouts, srv *Server) *http.Server {
+	return NewHTTPServerWithHandler(cors, vhosts, timeouts, srv)
+}
+
+// NewHTTPServerWithHandler creates a new HTTP server around the handler of the RPC server,
+// e.g. the RPC server wrapped by an authentication handler.
+func NewHTTPServerWithHandler(cors []string, vhosts []string, timeouts HTTPTimeouts, next http.Handler) *http.Server {
 	// Wrap the CORS-handler within a host-handler
-	handler := newCorsHandler(srv, cors)
+	handler := newCorsHandler(next, cors)
 	handler = newVHostHandler(vhosts, handler)
 
 	// Make sure timeout values are meaningful
@@ -284,7 +290,7 @@ func validateRequest(r *http.Request) (int, error) {
 	return http.StatusUnsupportedMediaType, err
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -0,0 +1,22 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var errIPCNotSupported = errors.New("IPC is not supported on this platform")
+
+// ipcListen is not supported on this platform.
+func ipcListen(endpoint string) (net.Listener, error) {
+	return nil, errIPCNotSupported
+}
+
+// newIPCConnection is not supported on this platform.
+func newIPCConnection(ctx context.Context, endpoint string) (net.Conn, error) {
+	return nil, errIPCNotSupported
+}
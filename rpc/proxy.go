@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// ProxyRequest is a request of the method not registered in the server,
+// Params is empty or a JSON array, the proxy sets the Result or the Error of it.
+type ProxyRequest struct {
+	Method string
+	Params json.RawMessage
+	Result json.RawMessage
+	Error  error
+}
+
+// Proxy handles the methods not registered in the server, e.g. forwards them to an upstream node.
+type Proxy interface {
+	// Proxies returns true if the method is handled by the proxy
+	Proxies(method string) bool
+	// Call handles the requests, the requests of a batch are handled in one call
+	Call(ctx context.Context, reqs []*ProxyRequest)
+}
+
+// SetProxy sets the proxy for the methods not registered in the server.
+// It must be called before the server starts serving.
+func (s *Server) SetProxy(proxy Proxy) {
+	s.proxy = proxy
+}
+
+// proxyRequest returns the server request handled by the proxy, or nil if the method is not proxied
+func (s *Server) proxyRequest(r rpcRequest) *serverRequest {
+	if s.proxy == nil || r.isPubSub {
+		return nil
+	}
+
+	method := r.service + serviceMethodSeparator + r.method
+	if !s.proxy.Proxies(method) {
+		return nil
+	}
+
+	req := &ProxyRequest{Method: method}
+	if params, ok := r.params.(json.RawMessage); ok {
+		// only positional params are forwarded
+		trimmed := bytes.TrimSpace(params)
+		switch {
+		case len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")):
+		case trimmed[0] == '[':
+			req.Params = params
+		default:
+			return &serverRequest{id: r.id, err: &invalidParamsError{"non-array args"}}
+		}
+	}
+
+	return &serverRequest{id: r.id, proxy: req}
+}
+
+// proxyResponse creates the response of the request handled by the proxy
+func proxyResponse(codec ServerCodec, req *serverRequest) interface{} {
+	if err := req.proxy.Error; err != nil {
+		if e, ok := err.(Error); ok {
+			return codec.CreateErrorResponse(&req.id, e)
+		}
+		return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()})
+	}
+
+	return codec.CreateResponse(req.id, req.proxy.Result)
+}
@@ -18,12 +18,14 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/log"
@@ -169,6 +171,8 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			return nil
 		}
 
+		s.filterRequests(ctx, reqs)
+
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
@@ -212,8 +216,13 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.ServeCodecContext(context.Background(), codec, options)
+}
+
+// ServeCodecContext is ServeCodec with the context of the connection, which is passed to the call filter.
+func (s *Server) ServeCodecContext(ctx context.Context, codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(context.Background(), codec, false, options)
+	s.serveRequest(ctx, codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
@@ -223,6 +232,47 @@ func (s *Server) ServeSingleRequest(ctx context.Context, codec ServerCodec, opti
 	s.serveRequest(ctx, codec, true, options)
 }
 
+// CallObserver is called after each method call with the start time and the error of the call
+type CallObserver func(method string, start time.Time, err error)
+
+// SetCallObserver sets the observer of the method calls, e.g. for metrics.
+// It must be called before the server starts serving.
+func (s *Server) SetCallObserver(observer CallObserver) {
+	s.observer = observer
+}
+
+// CallFilter is called before each method call with the context of the connection,
+// the call is rejected with the returned error, e.g. for the permissions and quotas of the API keys
+type CallFilter func(ctx context.Context, method string, params json.RawMessage) error
+
+// SetCallFilter sets the filter of the method calls.
+// It must be called before the server starts serving.
+func (s *Server) SetCallFilter(filter CallFilter) {
+	s.filter = filter
+}
+
+// filterRequests rejects the requests by the call filter
+func (s *Server) filterRequests(ctx context.Context, reqs []*serverRequest) {
+	if s.filter == nil {
+		return
+	}
+	for _, req := range reqs {
+		if req.err != nil {
+			continue
+		}
+		params, _ := req.params.(json.RawMessage)
+		if err := s.filter(ctx, req.call, params); err != nil {
+			req.err, req.proxy = &callbackError{err.Error()}, nil
+		}
+	}
+}
+
+func (s *Server) observe(method string, start time.Time, err error) {
+	if s.observer != nil {
+		s.observer(method, start, err)
+	}
+}
+
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
 // close all codecs which will cancel pending requests/subscriptions.
 func (s *Server) Stop() {
@@ -257,6 +307,13 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
 
+	if req.proxy != nil {
+		start := time.Now()
+		s.proxy.Call(ctx, []*ProxyRequest{req.proxy})
+		s.observe(req.proxy.Method, start, req.proxy.Error)
+		return proxyResponse(codec, req), nil
+	}
+
 	if req.isUnsubscribe { // cancel subscription, first param must be the subscription id
 		if len(req.args) >= 1 && req.args[0].Kind() == reflect.String {
 			notifier, supported := NotifierFromContext(ctx)
@@ -306,16 +363,19 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	// execute RPC method and return result
+	start := time.Now()
 	reply := req.callb.method.Func.Call(arguments)
+	var err error
+	if req.callb.errPos >= 0 && !reply[req.callb.errPos].IsNil() { // test if method returned an error
+		err = reply[req.callb.errPos].Interface().(error)
+	}
+	s.observe(req.method, start, err)
+
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
-	if req.callb.errPos >= 0 { // test if method returned an error
-		if !reply[req.callb.errPos].IsNil() {
-			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
-		}
+	if err != nil {
+		return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
@@ -345,10 +405,28 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 // It will only write the response back when the last request is processed.
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
+
+	// the requests handled by the proxy are sent in one call
+	var proxyReqs []*ProxyRequest
+	for _, req := range requests {
+		if req.err == nil && req.proxy != nil {
+			proxyReqs = append(proxyReqs, req.proxy)
+		}
+	}
+	if len(proxyReqs) > 0 {
+		start := time.Now()
+		s.proxy.Call(ctx, proxyReqs)
+		for _, req := range proxyReqs {
+			s.observe(req.Method, start, req.Error)
+		}
+	}
+
 	var callbacks []func()
 	for i, req := range requests {
 		if req.err != nil {
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
+		} else if req.proxy != nil {
+			responses[i] = proxyResponse(codec, req)
 		} else {
 			var callback func()
 			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
@@ -401,7 +479,9 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
-			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+			if requests[i] = s.proxyRequest(r); requests[i] == nil {
+				requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+			}
 			continue
 		}
 
@@ -424,7 +504,8 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb,
+				method: r.service + serviceMethodSeparator + r.method}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
@@ -435,7 +516,13 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
-		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+		if requests[i] = s.proxyRequest(r); requests[i] == nil {
+			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+		}
+	}
+
+	for i, r := range reqs {
+		requests[i].call, requests[i].params = r.callName(), r.params
 	}
 
 	return requests, batch, nil
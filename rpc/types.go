@@ -57,9 +57,13 @@ type service struct {
 type serverRequest struct {
 	id            interface{}
 	svcname       string
+	method        string // <service>_<method> of the request
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
+	proxy         *ProxyRequest // handled by the proxy of the server
+	call          string        // the method name as sent, checked by the call filter
+	params        interface{}   // the raw params, checked by the call filter
 	err           Error
 }
 
@@ -70,6 +74,9 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 // Server represents a RPC server
 type Server struct {
 	services serviceRegistry
+	proxy    Proxy
+	observer CallObserver
+	filter   CallFilter
 
 	run      int32
 	codecsMu sync.Mutex
@@ -86,6 +93,18 @@ type rpcRequest struct {
 	err      Error // invalid batch element
 }
 
+// callName returns the method name of the request as sent, e.g. newton_sendTransaction or eth_subscribe
+func (r *rpcRequest) callName() string {
+	switch {
+	case r.isPubSub && r.service == "": // unsubscribe
+		return r.method
+	case r.isPubSub:
+		return r.service + subscribeMethodSuffix
+	default:
+		return r.service + serviceMethodSeparator + r.method
+	}
+}
+
 // Error wraps RPC errors, which contain an error code in addition to the message.
 type Error interface {
 	Error() string  // returns the message
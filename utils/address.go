@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newAddressPrefix is the prefix of the NEW format address, NEW + base58check(chainID || address)
+const newAddressPrefix = "NEW"
+
+var (
+	ErrNotNewAddress     = errors.New("not NEW address")
+	ErrInvalidNewAddress = errors.New("invalid NEW address")
+)
+
+// AddressToNew encodes the address with the chain ID to the NEW format
+func AddressToNew(chainID uint64, address common.Address) string {
+	input := append(new(big.Int).SetUint64(chainID).Bytes(), address.Bytes()...)
+	return newAddressPrefix + base58.CheckEncode(input, 0)
+}
+
+// IsNewAddress returns true if s has the NEW prefix
+func IsNewAddress(s string) bool {
+	return strings.HasPrefix(s, newAddressPrefix)
+}
+
+// DecodeNewAddress decodes the NEW format address to the embedded chain ID and the address
+func DecodeNewAddress(newAddress string) (uint64, common.Address, error) {
+	if !IsNewAddress(newAddress) {
+		return 0, common.Address{}, ErrNotNewAddress
+	}
+
+	decoded, version, err := base58.CheckDecode(newAddress[len(newAddressPrefix):])
+	if err != nil {
+		return 0, common.Address{}, err
+	}
+	if version != 0 {
+		return 0, common.Address{}, errors.New("illegal version")
+	}
+	if len(decoded) < common.AddressLength || len(decoded) > common.AddressLength+8 {
+		return 0, common.Address{}, errors.New("illegal decoded length")
+	}
+
+	n := len(decoded) - common.AddressLength
+	chainID := new(big.Int).SetBytes(decoded[:n]).Uint64()
+
+	return chainID, common.BytesToAddress(decoded[n:]), nil
+}
+
+// NewToAddress decodes the NEW format address and checks the embedded chain ID
+func NewToAddress(chainID uint64, newAddress string) (common.Address, error) {
+	embedded, address, err := DecodeNewAddress(newAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if embedded != chainID {
+		return common.Address{}, fmt.Errorf("illegal ChainID %d, want %d", embedded, chainID)
+	}
+
+	return address, nil
+}
+
+// ParseAddress parses the hex or NEW format address, the chain ID of the NEW format is checked
+func ParseAddress(chainID uint64, s string) (common.Address, error) {
+	if common.IsHexAddress(s) {
+		return common.HexToAddress(s), nil
+	}
+	if IsNewAddress(s) {
+		return NewToAddress(chainID, s)
+	}
+
+	return common.Address{}, ErrInvalidAddress
+}
+
+// Address is the address argument in hex or NEW format,
+// the chain ID of the NEW format should be checked by CheckChainID
+type Address struct {
+	common.Address
+	ChainID uint64 // the chain ID embedded in the NEW format, 0 for hex
+}
+
+// UnmarshalJSON parses the hex or NEW format address
+func (a *Address) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+
+	if common.IsHexAddress(s) {
+		*a = Address{Address: common.HexToAddress(s)}
+		return nil
+	}
+	if !IsNewAddress(s) {
+		return ErrInvalidAddress
+	}
+
+	chainID, address, err := DecodeNewAddress(s)
+	if err != nil {
+		return ErrInvalidNewAddress
+	}
+	*a = Address{Address: address, ChainID: chainID}
+
+	return nil
+}
+
+// CheckChainID returns error if the address is in NEW format of another chain
+func (a *Address) CheckChainID(chainID uint64) error {
+	if a == nil || a.ChainID == 0 || a.ChainID == chainID {
+		return nil
+	}
+
+	return fmt.Errorf("address %s is on chain %d, not %d", a.Address.String(), a.ChainID, chainID)
+}
+
+// AddressPtr returns the common address, nil if a is nil
+func (a *Address) AddressPtr() *common.Address {
+	if a == nil {
+		return nil
+	}
+	address := a.Address
+	return &address
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewAddress(t *testing.T) {
+	address := common.HexToAddress("0x97549e368acafdcae786bb93d98379f1d1561a29")
+
+	newAddress := AddressToNew(1007, address)
+	if !IsNewAddress(newAddress) {
+		t.Fatalf("%s is not NEW address", newAddress)
+	}
+	if decoded, err := NewToAddress(1007, newAddress); err != nil || decoded != address {
+		t.Fatalf("decoded = %s, %v, want %s", decoded.String(), err, address.String())
+	}
+	if _, err := NewToAddress(1012, newAddress); err == nil {
+		t.Error("NEW address of another chain accepted")
+	}
+	if _, err := ParseAddress(1007, newAddress[:len(newAddress)-1]+"1"); err == nil {
+		t.Error("NEW address with bad checksum accepted")
+	}
+	if parsed, err := ParseAddress(1012, address.Hex()); err != nil || parsed != address {
+		t.Errorf("parsed = %s, %v, want %s", parsed.String(), err, address.String())
+	}
+
+	var args struct {
+		From Address  `json:"from"`
+		To   *Address `json:"to"`
+	}
+	input := `{"from":"` + newAddress + `","to":"` + address.Hex() + `"}`
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.From.Address != address || args.From.ChainID != 1007 || args.To.Address != address || args.To.ChainID != 0 {
+		t.Fatalf("args = %+v, want the address", args)
+	}
+	if args.From.CheckChainID(1007) != nil || args.From.CheckChainID(1012) == nil || args.To.CheckChainID(1012) != nil {
+		t.Error("CheckChainID mismatch")
+	}
+	if data, _ := json.Marshal(&args.From); string(data) != `"0x97549e368acafdcae786bb93d98379f1d1561a29"` {
+		t.Errorf("marshaled = %s, want hex", data)
+	}
+}
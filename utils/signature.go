@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	secp256r1N     = elliptic.P256().Params().N
+	secp256r1halfN = new(big.Int).Div(secp256r1N, big.NewInt(2))
+)
+
+var (
+	ErrInvalidSignatureLength = errors.New("invalid signature length")
+	ErrUnrecoverableSignature = errors.New("invalid signature, could not construct a recoverable key")
+)
+
+// TextHash returns the hash of the personal_sign message,
+// keccak256("\x19Ethereum Signed Message:\n"${message length}${message})
+func TextHash(message []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256([]byte(msg))
+}
+
+// RecoverSignature searches the recovery ID of the 64 bytes [R || S] signature, or the 65 bytes one
+// whose V is ignored, and returns the 65 bytes [R || S || V] signature recovered to the address.
+// The S in the upper range is normalized (ECDSA malleability).
+func RecoverSignature(hash, sign []byte, address common.Address) ([]byte, error) {
+	if len(sign) != 64 && len(sign) != 65 {
+		return nil, ErrInvalidSignatureLength
+	}
+
+	signature := make([]byte, 32*2+1)
+	copy(signature[:32], sign[:32]) // r
+
+	// check s
+	// update upper range of s values (ECDSA malleability)
+	// see discussion in secp256k1/libsecp256k1/include/secp256k1.h
+	signS := big.NewInt(0).SetBytes(sign[32:64])
+	if signS.Cmp(secp256r1halfN) > 0 {
+		signS = new(big.Int).Sub(secp256r1N, signS)
+	}
+	sBytes := signS.Bytes()
+	copy(signature[64-len(sBytes):], sBytes) // s
+
+	for recID := byte(0); recID < 4; recID++ {
+		signature[64] = recID // v
+		pk, _ := crypto.SigToPub(hash, signature)
+		if pk != nil && crypto.PubkeyToAddress(*pk) == address {
+			return signature, nil
+		}
+	}
+
+	return nil, ErrUnrecoverableSignature
+}
+
+// RecoverSigner returns the address recovered from the 65 bytes [R || S || V] signature,
+// V is 0, 1 or 27, 28 as personal_sign
+func RecoverSigner(hash, sign []byte) (common.Address, error) {
+	if len(sign) != 65 {
+		return common.Address{}, ErrInvalidSignatureLength
+	}
+	signature := common.CopyBytes(sign)
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+
+	pk, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if pk == nil {
+		return common.Address{}, ErrUnrecoverableSignature
+	}
+
+	return crypto.PubkeyToAddress(*pk), nil
+}
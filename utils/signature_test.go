@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the example of EIP-712
+const testTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallet", "type": "address"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+    "to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+    "contents": "Hello, Bob!"
+  }
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	typedData, err := ParseTypedData([]byte(testTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodedType, err := typedData.EncodeType("Mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; encodedType != want {
+		t.Errorf("encodeType = %s, want %s", encodedType, want)
+	}
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; hexutil.Encode(domainSeparator) != want {
+		t.Errorf("domain separator = %s, want %s", hexutil.Encode(domainSeparator), want)
+	}
+
+	hash, err := typedData.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; hexutil.Encode(hash) != want {
+		t.Errorf("hash = %s, want %s", hexutil.Encode(hash), want)
+	}
+
+	delete(typedData.Message, "contents")
+	if _, err := typedData.Hash(); err == nil {
+		t.Error("missing value accepted")
+	}
+}
+
+func TestRecoverSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	hash := TextHash([]byte("hello"))
+	sign, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recovery ID is searched for the 64 bytes signature
+	signature, err := RecoverSignature(hash, sign[:64], address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signature[:64], sign[:64]) {
+		t.Fatalf("signature = %x, want %x", signature[:64], sign[:64])
+	}
+	signer, err := RecoverSigner(hash, signature)
+	if err != nil || signer != address {
+		t.Fatalf("signer = %s %v, want %s", signer.String(), err, address.String())
+	}
+
+	if _, err := RecoverSignature(hash, sign[:64], common.Address{}); err != ErrUnrecoverableSignature {
+		t.Fatalf("err = %v, want unrecoverable", err)
+	}
+	if _, err := RecoverSignature(TextHash([]byte("hello!")), sign, address); err != ErrUnrecoverableSignature {
+		t.Fatalf("err = %v, want unrecoverable", err)
+	}
+	if _, err := RecoverSignature(hash, sign[:63], address); err != ErrInvalidSignatureLength {
+		t.Fatalf("err = %v, want invalid length", err)
+	}
+}
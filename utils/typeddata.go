@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// eip712Domain is the type name of the domain of the EIP-712 typed data
+const eip712Domain = "EIP712Domain"
+
+// TypedDataField is the name and type of a struct member of the EIP-712 typed data
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is the EIP-712 typed data as eth_signTypedData,
+// the integers are in JSON numbers, decimal or hex strings
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// ParseTypedData parses the typed data JSON, the numbers are kept exact
+func ParseTypedData(data []byte) (*TypedData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var typedData TypedData
+	if err := decoder.Decode(&typedData); err != nil {
+		return nil, err
+	}
+
+	return &typedData, nil
+}
+
+// Hash returns the hash to sign, keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (t *TypedData) Hash() ([]byte, error) {
+	if _, ok := t.Types[eip712Domain]; !ok {
+		return nil, fmt.Errorf("missing type %s", eip712Domain)
+	}
+	domainSeparator, err := t.HashStruct(eip712Domain, t.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte{0x19, 0x01}, domainSeparator...)
+	if t.PrimaryType != eip712Domain {
+		messageHash, err := t.HashStruct(t.PrimaryType, t.Message)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, messageHash...)
+	}
+
+	return crypto.Keccak256(data), nil
+}
+
+// HashStruct returns keccak256(typeHash ‖ encodeData(s))
+func (t *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+	encoded, err := t.encodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.Keccak256(encoded), nil
+}
+
+// EncodeType returns the type encoding, the referenced struct types are appended in alphabetical order
+func (t *TypedData) EncodeType(primaryType string) (string, error) {
+	deps := make(map[string]bool)
+	if err := t.dependencies(primaryType, deps); err != nil {
+		return "", err
+	}
+	delete(deps, primaryType)
+
+	types := make([]string, 0, len(deps))
+	for dep := range deps {
+		types = append(types, dep)
+	}
+	sort.Strings(types)
+	types = append([]string{primaryType}, types...)
+
+	var buffer strings.Builder
+	for _, name := range types {
+		fields := make([]string, 0, len(t.Types[name]))
+		for _, field := range t.Types[name] {
+			fields = append(fields, field.Type+" "+field.Name)
+		}
+		buffer.WriteString(name + "(" + strings.Join(fields, ",") + ")")
+	}
+
+	return buffer.String(), nil
+}
+
+func (t *TypedData) dependencies(primaryType string, deps map[string]bool) error {
+	if deps[primaryType] {
+		return nil
+	}
+	fields, ok := t.Types[primaryType]
+	if !ok {
+		return fmt.Errorf("unknown type %s", primaryType)
+	}
+	deps[primaryType] = true
+
+	for _, field := range fields {
+		base := arrayBaseType(field.Type)
+		if _, ok := t.Types[base]; ok {
+			if err := t.dependencies(base, deps); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *TypedData) encodeData(primaryType string, data map[string]interface{}) ([]byte, error) {
+	encodedType, err := t.EncodeType(primaryType)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := crypto.Keccak256([]byte(encodedType))
+	for _, field := range t.Types[primaryType] {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing value of %s.%s", primaryType, field.Name)
+		}
+		word, err := t.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", primaryType, field.Name, err)
+		}
+		encoded = append(encoded, word...)
+	}
+
+	return encoded, nil
+}
+
+// encodeValue encodes the value to 32 bytes, the dynamic and the struct values are hashed
+func (t *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	if strings.HasSuffix(typ, "]") {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not array", value)
+		}
+		i := strings.LastIndex(typ, "[")
+		if size := typ[i+1 : len(typ)-1]; size != "" {
+			if n, err := strconv.Atoi(size); err != nil || n != len(items) {
+				return nil, fmt.Errorf("array length %d mismatch %s", len(items), typ)
+			}
+		}
+
+		var encoded []byte
+		for _, item := range items {
+			word, err := t.encodeValue(typ[:i], item)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, word...)
+		}
+		return crypto.Keccak256(encoded), nil
+	}
+
+	if _, ok := t.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not struct %s", value, typ)
+		}
+		return t.HashStruct(typ, data)
+	}
+
+	switch {
+	case typ == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not string", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+	case typ == "bytes":
+		b, err := decodeTypedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	case typ == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v is not bool", value)
+		}
+		word := make([]byte, 32)
+		if b {
+			word[31] = 1
+		}
+		return word, nil
+	case typ == "address":
+		s, ok := value.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return nil, ErrInvalidAddress
+		}
+		return common.LeftPadBytes(common.HexToAddress(s).Bytes(), 32), nil
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(typ[len("bytes"):])
+		if err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("unknown type %s", typ)
+		}
+		b, err := decodeTypedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("length %d mismatch %s", len(b), typ)
+		}
+		return common.RightPadBytes(b, 32), nil
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		return encodeTypedInt(typ, value)
+	}
+
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func encodeTypedInt(typ string, value interface{}) ([]byte, error) {
+	signed := strings.HasPrefix(typ, "int")
+	bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"))
+	if err != nil || bits < 8 || bits > 256 || bits%8 != 0 {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	var n *big.Int
+	switch v := value.(type) {
+	case json.Number:
+		n, _ = new(big.Int).SetString(v.String(), 10)
+	case string:
+		if strings.HasPrefix(v, "0x") || strings.HasPrefix(v, "0X") {
+			n, _ = new(big.Int).SetString(v[2:], 16)
+		} else {
+			n, _ = new(big.Int).SetString(v, 10)
+		}
+	case float64:
+		// the numbers decoded without UseNumber, only exact for the safe integers
+		if v == float64(int64(v)) && v <= 1<<53 && v >= -(1<<53) {
+			n = big.NewInt(int64(v))
+		}
+	}
+	if n == nil {
+		return nil, fmt.Errorf("%v is not %s", value, typ)
+	}
+
+	min, max := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("%s overflows %s", n.String(), typ)
+	}
+
+	return math.PaddedBigBytes(math.U256(n), 32), nil
+}
+
+func decodeTypedBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not hex bytes", value)
+	}
+
+	return hexutil.Decode(s)
+}
+
+// arrayBaseType strips the array suffixes, Person[][2] => Person
+func arrayBaseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}